@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gocontrib/auth"
+)
+
+// Handler serves audit log queries to admin users.
+// Supported query parameters: user, type (repeatable), from, to (RFC 3339) and limit.
+func Handler(config *auth.Config, l *Log) http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseQuery(r)
+		if err != nil {
+			auth.SendRequestError(config, w, r, auth.ErrMalformedContent.WithCause(err))
+			return
+		}
+		records, err := l.Query(*q)
+		if err != nil {
+			auth.SendRequestError(config, w, r, auth.ErrBadState.WithCause(err))
+			return
+		}
+		auth.SendJSON(w, records)
+	})
+	return auth.RequireAdmin(config)(h)
+}
+
+func parseQuery(r *http.Request) (*Query, error) {
+	values := r.URL.Query()
+	q := &Query{
+		User: values.Get("user"),
+	}
+	for _, t := range values["type"] {
+		q.Types = append(q.Types, auth.EventType(t))
+	}
+
+	var err error
+	if s := values.Get("from"); len(s) > 0 {
+		if q.From, err = time.Parse(time.RFC3339, s); err != nil {
+			return nil, err
+		}
+	}
+	if s := values.Get("to"); len(s) > 0 {
+		if q.To, err = time.Parse(time.RFC3339, s); err != nil {
+			return nil, err
+		}
+	}
+	if s := values.Get("limit"); len(s) > 0 {
+		if q.Limit, err = strconv.Atoi(s); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
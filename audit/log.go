@@ -0,0 +1,437 @@
+// Package audit implements tamper-evident persistent log of authentication events.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gocontrib/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+const rotatedSuffixFormat = "20060102T150405.000000000"
+
+// ErrNoKey is returned by Open if Options.Key is empty.
+var ErrNoKey = errors.New("audit: hash chain key is required")
+
+// syncFile flushes appended record to disk.
+var syncFile = (*os.File).Sync
+
+// Options defines audit log location and rotation.
+type Options struct {
+	// Path of active log file, rotated files get timestamp suffix
+	Path string
+	// Key is required HMAC-SHA256 key of hash chain. Whoever knows it can rewrite the log,
+	// so keep it out of reach of those who can write log files, e.g. in secret manager.
+	Key []byte
+	// MaxSize rotates log when file exceeds given number of bytes, zero disables size rotation
+	MaxSize int64
+	// Daily rotates log when date changes
+	Daily bool
+	// Logger reports append failures, defaults to standard logrus logger
+	Logger auth.Logger
+}
+
+// Record is a line of audit log chained with previous one by hash.
+type Record struct {
+	Seq      int64      `json:"seq"`
+	Event    auth.Event `json:"event"`
+	PrevHash string     `json:"prev_hash"`
+	Hash     string     `json:"hash"`
+}
+
+// computeHash returns keyed hash of the record content including previous hash.
+func (r Record) computeHash(key []byte) (string, error) {
+	r.Hash = ""
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Checkpoint is position in hash chain. Keep last checkpoint outside of the log host
+// to detect truncated tail, or pass checkpoint of the last pruned record to VerifyFiles
+// after old files are removed by retention.
+type Checkpoint struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// Log is append-only JSON lines file with hash chaining.
+// It implements auth.EventSink.
+type Log struct {
+	sync.Mutex
+	opts     Options
+	file     *os.File
+	size     int64
+	day      string
+	seq      int64
+	lastHash string
+}
+
+// Open opens audit log and resumes hash chain from its last record.
+func Open(opts Options) (*Log, error) {
+	if len(opts.Key) == 0 {
+		return nil, ErrNoKey
+	}
+	if opts.Logger == nil {
+		opts.Logger = auth.LogrusLogger(log.StandardLogger())
+	}
+	l := &Log{opts: opts}
+
+	// record torn by crash or failed write cannot be completed, drop it to resume the chain
+	torn, err := repairTail(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	if torn > 0 {
+		opts.Logger.Log(context.Background(), auth.LevelWarn, "audit log had torn last record, truncated",
+			auth.Fields{"file": opts.Path, "bytes": torn})
+	}
+
+	files, err := l.Files()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		last, err := readLastRecord(files[i])
+		if err != nil {
+			return nil, err
+		}
+		if last != nil {
+			l.seq = last.Seq
+			l.lastHash = last.Hash
+			break
+		}
+	}
+
+	if err := l.openFile(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) openFile() error {
+	f, err := os.OpenFile(l.opts.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file = f
+	l.size = info.Size()
+	l.day = info.ModTime().UTC().Format("2006-01-02")
+	if l.size == 0 {
+		l.day = time.Now().UTC().Format("2006-01-02")
+	}
+	return nil
+}
+
+// Emit appends event to the log, errors are logged.
+func (l *Log) Emit(ctx context.Context, event auth.Event) {
+	if err := l.Append(event); err != nil {
+		l.opts.Logger.Log(ctx, auth.LevelError, "audit log failed", auth.RedactFields(auth.Fields{"error": err}))
+	}
+}
+
+// Append writes event chained to previous record.
+func (l *Log) Append(event auth.Event) error {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.file == nil {
+		return os.ErrClosed
+	}
+
+	event.Time = event.Time.UTC()
+	record := Record{
+		Seq:      l.seq + 1,
+		Event:    event,
+		PrevHash: l.lastHash,
+	}
+	hash, err := record.computeHash(l.opts.Key)
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if err := l.rotateIfNeeded(int64(len(line))); err != nil {
+		return err
+	}
+
+	_, err = l.file.Write(line)
+	if err == nil {
+		err = syncFile(l.file)
+	}
+	if err != nil {
+		// drop partial record, so next one continues the chain
+		if terr := l.file.Truncate(l.size); terr != nil {
+			l.file.Close()
+			l.file = nil
+			return fmt.Errorf("%v, log closed as it cannot be truncated: %v", err, terr)
+		}
+		return err
+	}
+	l.size += int64(len(line))
+
+	l.seq = record.Seq
+	l.lastHash = record.Hash
+	return nil
+}
+
+func (l *Log) rotateIfNeeded(n int64) error {
+	if l.size == 0 {
+		return nil
+	}
+	today := time.Now().UTC().Format("2006-01-02")
+	bySize := l.opts.MaxSize > 0 && l.size+n > l.opts.MaxSize
+	byDate := l.opts.Daily && today != l.day
+	if !bySize && !byDate {
+		return nil
+	}
+
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+	rotated := l.opts.Path + "." + time.Now().UTC().Format(rotatedSuffixFormat)
+	if err := os.Rename(l.opts.Path, rotated); err != nil {
+		return err
+	}
+	return l.openFile()
+}
+
+// Close closes active log file.
+func (l *Log) Close() error {
+	l.Lock()
+	defer l.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Checkpoint returns position of the last appended record.
+func (l *Log) Checkpoint() Checkpoint {
+	l.Lock()
+	defer l.Unlock()
+	return Checkpoint{Seq: l.seq, Hash: l.lastHash}
+}
+
+// Files returns rotated files and active file in chronological order.
+// Only files with rotation timestamp suffix are considered rotated ones.
+func (l *Log) Files() ([]string, error) {
+	matches, err := filepath.Glob(l.opts.Path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var rotated []string
+	for _, name := range matches {
+		suffix := name[len(l.opts.Path)+1:]
+		if _, err := time.Parse(rotatedSuffixFormat, suffix); err == nil && len(suffix) == len(rotatedSuffixFormat) {
+			rotated = append(rotated, name)
+		}
+	}
+	sort.Strings(rotated)
+	if _, err := os.Stat(l.opts.Path); err == nil {
+		rotated = append(rotated, l.opts.Path)
+	}
+	return rotated, nil
+}
+
+// segment is log file opened for reading.
+type segment struct {
+	name string
+	file *os.File
+	r    io.Reader
+}
+
+// open opens log files under the lock, so they can be read without blocking Append.
+// Opened files survive rotation, active file is read up to its current size.
+func (l *Log) open() ([]segment, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	files, err := l.Files()
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]segment, 0, len(files))
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			closeSegments(segments)
+			return nil, err
+		}
+		var r io.Reader = f
+		if name == l.opts.Path && l.file != nil {
+			r = io.LimitReader(f, l.size)
+		}
+		segments = append(segments, segment{name: name, file: f, r: r})
+	}
+	return segments, nil
+}
+
+func closeSegments(segments []segment) {
+	for _, s := range segments {
+		s.file.Close()
+	}
+}
+
+// Verify checks integrity of hash chain across all log files starting from the first record
+// and returns checkpoint of the last one.
+func (l *Log) Verify() (Checkpoint, error) {
+	segments, err := l.open()
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	defer closeSegments(segments)
+	return verify(l.opts.Key, Checkpoint{}, segments)
+}
+
+// VerifyFiles checks integrity of hash chain in given files ordered chronologically
+// and returns checkpoint of the last record. Chain must continue from start,
+// zero start requires files to begin with the first record of the log.
+// Compare returned checkpoint with stored one to detect truncated tail.
+func VerifyFiles(key []byte, start Checkpoint, files ...string) (Checkpoint, error) {
+	segments := make([]segment, 0, len(files))
+	defer func() { closeSegments(segments) }()
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		segments = append(segments, segment{name: name, file: f, r: f})
+	}
+	return verify(key, start, segments)
+}
+
+func verify(key []byte, start Checkpoint, segments []segment) (Checkpoint, error) {
+	prev := start
+	for _, s := range segments {
+		err := scan(s.name, s.r, func(line int, r *Record) error {
+			hash, err := r.computeHash(key)
+			if err != nil {
+				return err
+			}
+			if !hmac.Equal([]byte(hash), []byte(r.Hash)) {
+				return fmt.Errorf("%s:%d: record hash mismatch", s.name, line)
+			}
+			if r.Seq != prev.Seq+1 {
+				return fmt.Errorf("%s:%d: expected seq %d, got %d", s.name, line, prev.Seq+1, r.Seq)
+			}
+			if r.PrevHash != prev.Hash {
+				return fmt.Errorf("%s:%d: broken hash chain", s.name, line)
+			}
+			prev = Checkpoint{Seq: r.Seq, Hash: r.Hash}
+			return nil
+		})
+		if err != nil {
+			return Checkpoint{}, err
+		}
+	}
+	return prev, nil
+}
+
+func scanFile(name string, fn func(line int, r *Record) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return scan(name, f, fn)
+}
+
+func scan(name string, reader io.Reader, fn func(line int, r *Record) error) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		r := &Record{}
+		if err := json.Unmarshal(b, r); err != nil {
+			return fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+		if err := fn(line, r); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func readLastRecord(name string) (*Record, error) {
+	var last *Record
+	err := scanFile(name, func(line int, r *Record) error {
+		last = r
+		return nil
+	})
+	return last, err
+}
+
+// repairTail truncates last line of the file if it is not terminated and returns number of removed bytes.
+func repairTail(name string) (int64, error) {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	end := size
+	buf := make([]byte, 4096)
+	for end > 0 {
+		n := int64(len(buf))
+		if n > end {
+			n = end
+		}
+		if _, err := f.ReadAt(buf[:n], end-n); err != nil {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			end += int64(i) + 1 - n
+			break
+		}
+		end -= n
+	}
+	if end == size {
+		return 0, nil
+	}
+	return size - end, f.Truncate(end)
+}
@@ -0,0 +1,302 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocontrib/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestLog(t *testing.T, opts Options) (*Log, func()) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.Nil(t, err)
+	opts.Path = filepath.Join(dir, "audit.log")
+	opts.Key = []byte("test key")
+	l, err := Open(opts)
+	assert.Nil(t, err)
+	return l, func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func appendEvents(t *testing.T, l *Log, n int) {
+	for i := 0; i < n; i++ {
+		user := "bob"
+		if i%2 == 1 {
+			user = "rob"
+		}
+		err := l.Append(auth.Event{
+			Type:     auth.EventLoginSuccess,
+			UserName: user,
+			Time:     time.Now(),
+		})
+		assert.Nil(t, err)
+	}
+}
+
+func TestLog_VerifyChain(t *testing.T) {
+	l, cleanup := openTestLog(t, Options{})
+	defer cleanup()
+
+	appendEvents(t, l, 5)
+	last, err := l.Verify()
+	assert.Nil(t, err)
+	assert.Equal(t, l.Checkpoint(), last)
+	assert.Equal(t, int64(5), last.Seq)
+
+	// resume chain after reopen
+	assert.Nil(t, l.Close())
+	l2, err := Open(l.opts)
+	assert.Nil(t, err)
+	defer l2.Close()
+	appendEvents(t, l2, 2)
+	last, err = l2.Verify()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), last.Seq)
+
+	records, err := l2.Query(Query{})
+	assert.Nil(t, err)
+	assert.Len(t, records, 7)
+	assert.Equal(t, int64(7), records[6].Seq)
+}
+
+func TestLog_DetectTampering(t *testing.T) {
+	l, cleanup := openTestLog(t, Options{})
+	defer cleanup()
+
+	appendEvents(t, l, 3)
+
+	b, err := ioutil.ReadFile(l.opts.Path)
+	assert.Nil(t, err)
+	tampered := strings.Replace(string(b), `"user_name":"rob"`, `"user_name":"joe"`, 1)
+	assert.Nil(t, ioutil.WriteFile(l.opts.Path, []byte(tampered), 0600))
+	_, err = VerifyFiles(l.opts.Key, Checkpoint{}, l.opts.Path)
+	assert.NotNil(t, err)
+
+	// removing a record breaks the chain
+	lines := strings.SplitAfter(string(b), "\n")
+	assert.Nil(t, ioutil.WriteFile(l.opts.Path, []byte(lines[0]+lines[2]), 0600))
+	_, err = VerifyFiles(l.opts.Key, Checkpoint{}, l.opts.Path)
+	assert.NotNil(t, err)
+
+	// removing the first record breaks genesis
+	assert.Nil(t, ioutil.WriteFile(l.opts.Path, []byte(lines[1]+lines[2]), 0600))
+	_, err = VerifyFiles(l.opts.Key, Checkpoint{}, l.opts.Path)
+	assert.NotNil(t, err)
+
+	// rewritten chain is rejected without the key
+	records, err := l.Query(Query{})
+	assert.Nil(t, err)
+	forged := ""
+	prev := ""
+	for _, r := range records {
+		r.Event.UserName = "joe"
+		r.PrevHash = prev
+		r.Hash, _ = r.computeHash([]byte("guessed key"))
+		prev = r.Hash
+		line, _ := json.Marshal(r)
+		forged += string(line) + "\n"
+	}
+	assert.Nil(t, ioutil.WriteFile(l.opts.Path, []byte(forged), 0600))
+	_, err = VerifyFiles(l.opts.Key, Checkpoint{}, l.opts.Path)
+	assert.NotNil(t, err)
+}
+
+func TestLog_DetectTruncation(t *testing.T) {
+	l, cleanup := openTestLog(t, Options{MaxSize: 300})
+	defer cleanup()
+
+	appendEvents(t, l, 5)
+	last := l.Checkpoint()
+	files, err := l.Files()
+	assert.Nil(t, err)
+
+	// deleted oldest file is detected unless chain is anchored at pruned record
+	_, err = VerifyFiles(l.opts.Key, Checkpoint{}, files[1:]...)
+	assert.NotNil(t, err)
+	first, err := VerifyFiles(l.opts.Key, Checkpoint{}, files[0])
+	assert.Nil(t, err)
+	end, err := VerifyFiles(l.opts.Key, first, files[1:]...)
+	assert.Nil(t, err)
+	assert.Equal(t, last, end)
+
+	// truncated tail is valid chain, but does not reach stored checkpoint
+	end, err = VerifyFiles(l.opts.Key, Checkpoint{}, files[:len(files)-1]...)
+	assert.Nil(t, err)
+	assert.NotEqual(t, last, end)
+}
+
+func TestLog_AppendDropsFailedRecord(t *testing.T) {
+	l, cleanup := openTestLog(t, Options{})
+	defer cleanup()
+	appendEvents(t, l, 2)
+
+	defer func(f func(*os.File) error) { syncFile = f }(syncFile)
+	syncFile = func(*os.File) error { return errors.New("disk failure") }
+	assert.NotNil(t, l.Append(auth.Event{Type: auth.EventLoginFailure}))
+	syncFile = (*os.File).Sync
+
+	appendEvents(t, l, 1)
+	last, err := l.Verify()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), last.Seq)
+	_, err = VerifyFiles(l.opts.Key, Checkpoint{}, l.opts.Path)
+	assert.Nil(t, err)
+}
+
+func TestOpen_TruncatesTornRecord(t *testing.T) {
+	l, cleanup := openTestLog(t, Options{})
+	defer cleanup()
+	appendEvents(t, l, 2)
+	assert.Nil(t, l.Close())
+
+	f, err := os.OpenFile(l.opts.Path, os.O_APPEND|os.O_WRONLY, 0)
+	assert.Nil(t, err)
+	_, err = f.WriteString(`{"seq":3,"event":{"ty`)
+	assert.Nil(t, err)
+	f.Close()
+
+	rec := &logRecorder{}
+	opts := l.opts
+	opts.Logger = rec
+	l2, err := Open(opts)
+	assert.Nil(t, err)
+	defer l2.Close()
+	assert.Len(t, rec.msgs, 1)
+
+	appendEvents(t, l2, 1)
+	last, err := l2.Verify()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), last.Seq)
+}
+
+type logRecorder struct {
+	msgs []string
+}
+
+func (r *logRecorder) Log(ctx context.Context, level auth.Level, msg string, fields auth.Fields) {
+	r.msgs = append(r.msgs, msg)
+}
+
+func TestLog_FilesIgnoresUnrelated(t *testing.T) {
+	l, cleanup := openTestLog(t, Options{})
+	defer cleanup()
+	appendEvents(t, l, 2)
+
+	for _, name := range []string{".bak", ".20200101T000000.000000000.gz", ".swp"} {
+		assert.Nil(t, ioutil.WriteFile(l.opts.Path+name, []byte("garbage\n"), 0600))
+	}
+	files, err := l.Files()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{l.opts.Path}, files)
+	_, err = l.Verify()
+	assert.Nil(t, err)
+}
+
+func TestOpen_RequiresKey(t *testing.T) {
+	_, err := Open(Options{Path: filepath.Join(os.TempDir(), "audit.log")})
+	assert.Equal(t, ErrNoKey, err)
+}
+
+func TestLog_RotateBySize(t *testing.T) {
+	l, cleanup := openTestLog(t, Options{MaxSize: 300})
+	defer cleanup()
+
+	appendEvents(t, l, 5)
+
+	files, err := l.Files()
+	assert.Nil(t, err)
+	assert.True(t, len(files) > 1)
+	_, err = l.Verify()
+	assert.Nil(t, err)
+
+	records, err := l.Query(Query{User: "rob"})
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+}
+
+func TestLog_Query(t *testing.T) {
+	l, cleanup := openTestLog(t, Options{})
+	defer cleanup()
+
+	appendEvents(t, l, 4)
+	l.Append(auth.Event{Type: auth.EventLoginFailure, UserName: "bob", Time: time.Now()})
+
+	records, err := l.Query(Query{User: "bob", Types: []auth.EventType{auth.EventLoginFailure}})
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+
+	records, err = l.Query(Query{To: time.Now().Add(-time.Hour)})
+	assert.Nil(t, err)
+	assert.Len(t, records, 0)
+
+	records, err = l.Query(Query{Limit: 2})
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, int64(5), records[1].Seq)
+}
+
+type testStore map[string]*auth.UserInfo
+
+func (s testStore) ValidateCredentials(ctx context.Context, username, password string) (auth.User, error) {
+	u, ok := s[username]
+	if !ok || u.Pwd != password {
+		return nil, errors.New("invalid credentials")
+	}
+	return u, nil
+}
+
+func (s testStore) FindUserByID(ctx context.Context, userID string) (auth.User, error) {
+	for _, u := range s {
+		if u.ID == userID {
+			return u, nil
+		}
+	}
+	return nil, errors.New("user not found")
+}
+
+func (s testStore) Close() {}
+
+func TestHandler(t *testing.T) {
+	l, cleanup := openTestLog(t, Options{})
+	defer cleanup()
+	appendEvents(t, l, 3)
+
+	config := &auth.Config{
+		UserStore: testStore{
+			"bob":   {ID: "1", Name: "bob", Pwd: "b0b"},
+			"admin": {ID: "2", Name: "admin", Pwd: "admin", Admin: true},
+		},
+	}
+	h := Handler(config, l)
+
+	r := httptest.NewRequest("GET", "/?user=bob", nil)
+	r.SetBasicAuth("bob", "b0b")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	r = httptest.NewRequest("GET", "/?user=bob&type=login_success", nil)
+	r.SetBasicAuth("admin", "admin")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, strings.Count(w.Body.String(), `"user_name":"bob"`))
+
+	r = httptest.NewRequest("GET", "/?from=yesterday", nil)
+	r.SetBasicAuth("admin", "admin")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
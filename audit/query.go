@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/gocontrib/auth"
+)
+
+// Query filters audit records.
+type Query struct {
+	// User matches user ID or user name
+	User  string
+	Types []auth.EventType
+	From  time.Time
+	To    time.Time
+	// Limit returns only last N matched records
+	Limit int
+}
+
+func (q *Query) match(e *auth.Event) bool {
+	if len(q.User) > 0 && e.UserID != q.User && e.UserName != q.User {
+		return false
+	}
+	if len(q.Types) > 0 {
+		found := false
+		for _, t := range q.Types {
+			if t == e.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !q.From.IsZero() && e.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !e.Time.Before(q.To) {
+		return false
+	}
+	return true
+}
+
+// Query returns records matching given query in chronological order.
+// Files are scanned without holding the lock, so Append is not blocked.
+func (l *Log) Query(q Query) ([]Record, error) {
+	segments, err := l.open()
+	if err != nil {
+		return nil, err
+	}
+	defer closeSegments(segments)
+
+	result := []Record{}
+	for _, s := range segments {
+		err := scan(s.name, s.r, func(line int, r *Record) error {
+			if q.match(&r.Event) {
+				result = append(result, *r)
+				if q.Limit > 0 && len(result) > q.Limit {
+					result = result[1:]
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// chainIDSeparator separates store name and user ID in IDs of ChainStore users.
+const chainIDSeparator = ":"
+
+var (
+	// ErrNoMatchingStore is returned by ChainStore when username does not match any member.
+	ErrNoMatchingStore = errors.New("auth: no user store matches username")
+	// ErrUnknownStore is returned by ChainStore when user ID has no known store prefix.
+	ErrUnknownStore = errors.New("auth: user id has unknown store")
+)
+
+// ChainMember is user store in ChainStore.
+type ChainMember struct {
+	// Name of the store, IDs of its users are prefixed with "name:".
+	// It must be unique within the chain and must not contain ':'.
+	Name  string
+	Store UserStore
+	// Match reports whether username can be validated by the store, nil matches any username.
+	// See MatchPattern and MatchDomain.
+	Match func(username string) bool
+	// TrimDomain passes username without '@domain' suffix to the store
+	TrimDomain bool
+	// Fallthrough lets usernames matched by Match be tried in next members if the store rejects them.
+	// By default matched usernames are validated only by this store, so local account named
+	// like user@corp cannot log in when LDAP rejects the password or is unavailable.
+	Fallthrough bool
+}
+
+// MatchPattern matches usernames with given regular expression, it panics if pattern is invalid.
+func MatchPattern(pattern string) func(username string) bool {
+	re := regexp.MustCompile(pattern)
+	return re.MatchString
+}
+
+// MatchDomain matches usernames like user@domain with any of given domains, case-insensitive.
+func MatchDomain(domains ...string) func(username string) bool {
+	suffixes := make([]string, len(domains))
+	for i, d := range domains {
+		suffixes[i] = "@" + strings.ToLower(strings.TrimPrefix(d, "@"))
+	}
+	return func(username string) bool {
+		username = strings.ToLower(username)
+		for _, s := range suffixes {
+			if strings.HasSuffix(username, s) && len(username) > len(s) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ChainStore validates credentials against member stores in order.
+// Members with Match own usernames they match: such usernames are validated only by matching members
+// and never by members without Match, unless Fallthrough is set. Other usernames are tried
+// in members without Match one by one until one of them accepts credentials.
+// IDs of returned users are namespaced by member name, so FindUserByID queries the store that owns the user.
+type ChainStore struct {
+	members []ChainMember
+}
+
+// NewChainStore creates chain of given stores, it panics if member names are invalid or not unique.
+func NewChainStore(members ...ChainMember) *ChainStore {
+	names := make(map[string]bool, len(members))
+	for _, m := range members {
+		if len(m.Name) == 0 || strings.Contains(m.Name, chainIDSeparator) {
+			panic(fmt.Sprintf("auth: invalid chain store name %q", m.Name))
+		}
+		if names[m.Name] {
+			panic(fmt.Sprintf("auth: duplicate chain store name %q", m.Name))
+		}
+		names[m.Name] = true
+	}
+	return &ChainStore{members: members}
+}
+
+// ValidateCredentials returns user of first member routed by username that accepts credentials.
+// If all routed members fail, error of the first one is returned.
+func (cs *ChainStore) ValidateCredentials(ctx context.Context, username, password string) (User, error) {
+	claimed := cs.claimed(username)
+	var firstErr error
+	for i := range cs.members {
+		m := &cs.members[i]
+		if m.Match == nil {
+			if claimed {
+				continue
+			}
+		} else if !m.Match(username) {
+			continue
+		}
+		name := username
+		if m.TrimDomain {
+			if i := strings.LastIndex(name, "@"); i > 0 {
+				name = name[:i]
+			}
+		}
+		user, err := m.Store.ValidateCredentials(ctx, name, password)
+		if err == nil {
+			return &ChainUser{User: user, Store: m.Name}, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if m.Match != nil && !m.Fallthrough {
+			break
+		}
+	}
+	if firstErr == nil {
+		return nil, ErrNoMatchingStore
+	}
+	return nil, firstErr
+}
+
+// claimed reports whether username is owned exclusively by members with Match.
+func (cs *ChainStore) claimed(username string) bool {
+	for _, m := range cs.members {
+		if m.Match != nil && !m.Fallthrough && m.Match(username) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindUserByID finds user in the member named by the ID prefix.
+func (cs *ChainStore) FindUserByID(ctx context.Context, userID string) (User, error) {
+	m, id := cs.route(userID)
+	if m == nil {
+		return nil, ErrUnknownStore
+	}
+	user, err := m.Store.FindUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &ChainUser{User: user, Store: m.Name}, nil
+}
+
+// UpgradePassword delegates to the member owning the user if it implements PasswordUpgrader.
+func (cs *ChainStore) UpgradePassword(ctx context.Context, user User, password string) error {
+	m, _ := cs.route(user.GetID())
+	if m == nil {
+		return ErrUnknownStore
+	}
+	upgrader, ok := m.Store.(PasswordUpgrader)
+	if !ok {
+		return nil
+	}
+	if cu, ok := user.(*ChainUser); ok {
+		user = cu.User
+	}
+	return upgrader.UpgradePassword(ctx, user, password)
+}
+
+func (cs *ChainStore) route(userID string) (*ChainMember, string) {
+	i := strings.Index(userID, chainIDSeparator)
+	if i < 0 {
+		return nil, ""
+	}
+	name := userID[:i]
+	for j := range cs.members {
+		if cs.members[j].Name == name {
+			return &cs.members[j], userID[i+1:]
+		}
+	}
+	return nil, ""
+}
+
+// Close closes all member stores.
+func (cs *ChainStore) Close() {
+	for _, m := range cs.members {
+		m.Store.Close()
+	}
+}
+
+// ChainUser is user of ChainStore member, its ID is prefixed with member name.
+type ChainUser struct {
+	User
+	// Store is name of the member that owns the user
+	Store string
+}
+
+// GetID returns namespaced ID of the user.
+func (u *ChainUser) GetID() string {
+	return u.Store + chainIDSeparator + u.User.GetID()
+}
+
+// Unwrap returns user of member store.
+func (u *ChainUser) Unwrap() User {
+	return u.User
+}
+
+var (
+	_ UserStore        = (*ChainStore)(nil)
+	_ PasswordUpgrader = (*ChainStore)(nil)
+)
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type closeCountingUserStore struct {
+	testUserStore
+	closed int
+}
+
+func (us *closeCountingUserStore) Close() {
+	us.closed++
+}
+
+func makeTestChainStore() (*ChainStore, *closeCountingUserStore, *upgradingUserStore) {
+	corp := &closeCountingUserStore{testUserStore: testUserStore{
+		"bob":   &UserInfo{ID: "1", Pwd: "corp"},
+		"admin": &UserInfo{ID: "2", Pwd: "admin", Admin: true},
+	}}
+	corp.init()
+	db := &upgradingUserStore{testUserStore: testUserStore{
+		"bob":      &UserInfo{ID: "1", Pwd: "db"},
+		"joe":      &UserInfo{ID: "3", Pwd: "j0e"},
+		"bob@corp": &UserInfo{ID: "4", Pwd: "db"},
+	}, upgraded: map[string]string{}}
+	db.init()
+	return NewChainStore(
+		ChainMember{Name: "ldap", Store: corp, Match: MatchDomain("corp"), TrimDomain: true},
+		ChainMember{Name: "db", Store: db},
+	), corp, db
+}
+
+func TestChainStore_ValidateCredentials(t *testing.T) {
+	ctx := context.Background()
+	cs, _, _ := makeTestChainStore()
+
+	user, err := cs.ValidateCredentials(ctx, "bob@CORP", "corp")
+	assert.Nil(t, err)
+	assert.Equal(t, "ldap:1", user.GetID())
+	assert.Equal(t, "bob", user.GetName())
+
+	// domain users never fall back to next store, even if it has account with the same name
+	_, err = cs.ValidateCredentials(ctx, "bob@corp", "db")
+	assert.NotNil(t, err)
+	user, err = cs.ValidateCredentials(ctx, "bob", "db")
+	assert.Nil(t, err)
+	assert.Equal(t, "db:1", user.GetID())
+
+	// usernames without domain never go to ldap
+	_, err = cs.ValidateCredentials(ctx, "bob", "corp")
+	assert.NotNil(t, err)
+
+	_, err = NewChainStore(ChainMember{Name: "ldap", Store: makeTestUserStore(), Match: MatchPattern(`^[a-z]+@corp$`)}).
+		ValidateCredentials(ctx, "bob", "b0b")
+	assert.Equal(t, ErrNoMatchingStore, err)
+}
+
+func TestChainStore_Fallthrough(t *testing.T) {
+	ctx := context.Background()
+	corp := makeTestUserStore()
+	db := testUserStore{"bob@corp": &UserInfo{ID: "4", Pwd: "db"}}
+	db.init()
+
+	// routing does not depend on order of members
+	cs := NewChainStore(
+		ChainMember{Name: "db", Store: db},
+		ChainMember{Name: "ldap", Store: corp, Match: MatchDomain("corp"), TrimDomain: true},
+	)
+	_, err := cs.ValidateCredentials(ctx, "bob@corp", "db")
+	assert.NotNil(t, err)
+	user, err := cs.ValidateCredentials(ctx, "bob@corp", "b0b")
+	assert.Nil(t, err)
+	assert.Equal(t, "ldap", user.(*ChainUser).Store)
+
+	cs = NewChainStore(
+		ChainMember{Name: "ldap", Store: corp, Match: MatchDomain("corp"), TrimDomain: true, Fallthrough: true},
+		ChainMember{Name: "db", Store: db},
+	)
+	user, err = cs.ValidateCredentials(ctx, "bob@corp", "db")
+	assert.Nil(t, err)
+	assert.Equal(t, "db:4", user.GetID())
+}
+
+func TestChainStore_FindUserByID(t *testing.T) {
+	ctx := context.Background()
+	cs, _, _ := makeTestChainStore()
+
+	user, err := cs.FindUserByID(ctx, "ldap:1")
+	assert.Nil(t, err)
+	assert.Equal(t, "ldap:1", user.GetID())
+	assert.Equal(t, "bob", user.GetName())
+	assert.Equal(t, "bob@test.net", user.GetEmail())
+
+	user, err = cs.FindUserByID(ctx, "db:3")
+	assert.Nil(t, err)
+	assert.Equal(t, "joe", user.GetName())
+
+	_, err = cs.FindUserByID(ctx, "db:2")
+	assert.NotNil(t, err)
+	_, err = cs.FindUserByID(ctx, "1")
+	assert.Equal(t, ErrUnknownStore, err)
+	_, err = cs.FindUserByID(ctx, "file:1")
+	assert.Equal(t, ErrUnknownStore, err)
+}
+
+func TestChainStore_UpgradePassword(t *testing.T) {
+	ctx := context.Background()
+	cs, _, db := makeTestChainStore()
+
+	user, _ := cs.ValidateCredentials(ctx, "admin@corp", "admin")
+	assert.Nil(t, cs.UpgradePassword(ctx, user, "admin"))
+	user, _ = cs.ValidateCredentials(ctx, "joe", "j0e")
+	assert.Nil(t, cs.UpgradePassword(ctx, user, "j0e"))
+	assert.Equal(t, map[string]string{"joe": "j0e"}, db.upgraded)
+}
+
+func TestChainStore_Close(t *testing.T) {
+	cs, corp, _ := makeTestChainStore()
+	cs.Close()
+	assert.Equal(t, 1, corp.closed)
+}
+
+func TestChainStore_InvalidNames(t *testing.T) {
+	store := makeTestUserStore()
+	assert.Panics(t, func() { NewChainStore(ChainMember{Store: store}) })
+	assert.Panics(t, func() { NewChainStore(ChainMember{Name: "a:b", Store: store}) })
+	assert.Panics(t, func() {
+		NewChainStore(ChainMember{Name: "db", Store: store}, ChainMember{Name: "db", Store: store})
+	})
+}
+
+func TestMatchDomain(t *testing.T) {
+	match := MatchDomain("corp", "@example.com")
+	assert.True(t, match("bob@corp"))
+	assert.True(t, match("bob@Example.COM"))
+	assert.False(t, match("@corp"))
+	assert.False(t, match("bob@notcorp"))
+	assert.False(t, match("bob"))
+}
+
+func TestChainStore_JWT(t *testing.T) {
+	cs, _, _ := makeTestChainStore()
+	config := (&Config{UserStore: cs}).SetDefaults()
+	c := makectx(t, config, middlewareServer(config))
+
+	for _, creds := range [][2]string{{"bob@corp", "corp"}, {"bob", "db"}, {"admin@corp", "admin"}} {
+		token := c.makeToken(creds[0], creds[1])
+		c.expect.GET("/data").
+			WithHeader(authorizationHeader, fmt.Sprintf("%s %s", schemeBearer, token)).
+			Expect().
+			Status(http.StatusOK)
+	}
+
+	c.expect.GET("/admin/data").WithBasicAuth("admin@corp", "admin").Expect().Status(http.StatusOK)
+	c.expect.GET("/admin/data").WithBasicAuth("bob@corp", "corp").Expect().Status(http.StatusForbidden)
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RFC 6750 error codes
+const (
+	bearerInvalidToken      = "invalid_token"
+	bearerInsufficientScope = "insufficient_scope"
+)
+
+// withChallenges attaches WWW-Authenticate challenges to 401 and 403 errors.
+func withChallenges(config *Config, err *Error) *Error {
+	if err.Status != http.StatusUnauthorized && err.Status != http.StatusForbidden {
+		return err
+	}
+
+	var challenges []string
+
+	params := []string{authParam("realm", config.Realm)}
+	if len(err.BearerError) > 0 {
+		params = append(params, authParam("error", err.BearerError))
+		params = append(params, authParam("error_description", err.Message))
+	}
+	challenges = append(challenges, "Bearer "+strings.Join(params, ", "))
+
+	// basic credentials can't help with insufficient privileges
+	if err.Status == http.StatusUnauthorized && !config.DisableBasicAuth {
+		challenges = append(challenges, "Basic "+authParam("realm", config.Realm))
+	}
+
+	return err.WithChallenges(challenges...)
+}
+
+func authParam(name, value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	return fmt.Sprintf(`%s="%s"`, name, value)
+}
@@ -18,12 +18,18 @@ func CheckTokenHandlerFunc(config *Config) http.HandlerFunc {
 			err = ErrUnsupportedAuthScheme
 		}
 		if err != nil {
-			SendError(w, err)
+			emitTokenRejected(config, r, nil, err)
+			SendRequestError(config, w, r, withChallenges(config, err))
 			return
 		}
-		_, user, err := validateJWT(config, r, tokenString)
+		token, user, err := validateJWT(config, r, tokenString)
+		if err == nil && token.Actor != nil {
+			// impersonation tokens must not be refreshed into regular sessions
+			err = ErrImpersonationRestricted
+		}
 		if err != nil {
-			SendError(w, err)
+			emitTokenRejected(config, r, token, err)
+			SendRequestError(config, w, r, withChallenges(config, err))
 			return
 		}
 		WriteLoginResponse(w, r, config, user)
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IPBinding specifies how token is bound to client IP address.
+type IPBinding int
+
+const (
+	// IPBindingExact requires client IP to match IP the token was issued for
+	IPBindingExact IPBinding = iota
+	// IPBindingSubnet requires client IP to be in the same /24 (IPv4) or /64 (IPv6) subnet
+	IPBindingSubnet
+	// IPBindingOff disables client IP check
+	IPBindingOff
+)
+
+const defaultTrustedProxyHeader = "X-Forwarded-For"
+
+var (
+	subnetMaskV4 = net.CIDRMask(24, 32)
+	subnetMaskV6 = net.CIDRMask(64, 128)
+)
+
+// ClientIP returns client IP address of given request.
+// Config.TrustedProxyHeader is respected only from Config.TrustedProxies.
+func ClientIP(config *Config, r *http.Request) string {
+	return getClientIP(config, r)
+}
+
+var getClientIP = func(config *Config, r *http.Request) string {
+	remoteIP := parseIP(r.RemoteAddr)
+	if remoteIP == nil {
+		return r.RemoteAddr
+	}
+	if !config.isTrustedProxy(remoteIP) {
+		return remoteIP.String()
+	}
+
+	// hops from client to last proxy
+	hops := proxyHops(config, r.Header)
+	if len(hops) == 0 {
+		return remoteIP.String()
+	}
+
+	// walk from nearest hop, the first untrusted one is client
+	client := remoteIP
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseIP(hops[i])
+		if ip == nil {
+			break
+		}
+		client = ip
+		if !config.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// proxyHops reads client and proxy IPs from configured proxy header only,
+// so headers the proxy does not control cannot be spoofed by clients.
+func proxyHops(config *Config, h http.Header) []string {
+	name := config.TrustedProxyHeader
+	if len(name) == 0 {
+		name = defaultTrustedProxyHeader
+	}
+	if strings.EqualFold(name, "Forwarded") {
+		return forwardedFor(h)
+	}
+	return splitList(h.Values(name))
+}
+
+// forwardedFor returns 'for' values of RFC 7239 Forwarded header.
+func forwardedFor(h http.Header) []string {
+	var result []string
+	for _, element := range splitList(h["Forwarded"]) {
+		for _, pair := range strings.Split(element, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+				result = append(result, strings.Trim(kv[1], `"`))
+			}
+		}
+	}
+	return result
+}
+
+func splitList(values []string) []string {
+	var result []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			s = strings.TrimSpace(s)
+			if len(s) > 0 {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+// parseIP parses IP address with optional port and IPv6 brackets.
+func parseIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	return net.ParseIP(s)
+}
+
+func (c *Config) isTrustedProxy(ip net.IP) bool {
+	for _, network := range c.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseTrustedProxies(config *Config, list []string) []*net.IPNet {
+	var result []*net.IPNet
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					bits = 8 * net.IPv4len
+				}
+				result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			config.Log(nil, LogInvalidConfig, LevelWarn, "invalid trusted proxy", Fields{"proxy": s, "error": err})
+			continue
+		}
+		result = append(result, network)
+	}
+	return result
+}
+
+// matchClientIP checks token client IP against actual one according to Config.IPBinding.
+func matchClientIP(config *Config, tokenIP, actualIP string) bool {
+	if config.IPBinding == IPBindingOff || len(tokenIP) == 0 || len(actualIP) == 0 {
+		return true
+	}
+	if config.IPBinding == IPBindingExact {
+		return tokenIP == actualIP
+	}
+
+	a := net.ParseIP(tokenIP)
+	b := net.ParseIP(actualIP)
+	if a == nil || b == nil {
+		return tokenIP == actualIP
+	}
+	if a4, b4 := a.To4(), b.To4(); a4 != nil || b4 != nil {
+		return a4 != nil && b4 != nil && a4.Mask(subnetMaskV4).Equal(b4.Mask(subnetMaskV4))
+	}
+	return a.Mask(subnetMaskV6).Equal(b.Mask(subnetMaskV6))
+}
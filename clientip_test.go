@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP_UntrustedProxy(t *testing.T) {
+	config := defaultConfig()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "1.2.3.4")
+	r.Header.Set("X-Real-IP", "1.2.3.4")
+	assert.Equal(t, "10.0.0.1", ClientIP(config, r))
+}
+
+func TestClientIP_TrustedProxy(t *testing.T) {
+	config := &Config{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"}}
+	config.SetDefaults()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "6.6.6.6, 1.2.3.4, 192.168.1.1")
+	assert.Equal(t, "1.2.3.4", ClientIP(config, r))
+
+	// headers other than configured one are passed from client as is
+	r = httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("Forwarded", `for=6.6.6.6`)
+	r.Header.Set("X-Real-IP", "6.6.6.6")
+	r.Header.Set("X-Forwarded-For", "1.2.3.4")
+	assert.Equal(t, "1.2.3.4", ClientIP(config, r))
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Real-IP", "1.2.3.4")
+	assert.Equal(t, "10.0.0.1", ClientIP(config, r))
+}
+
+func TestClientIP_TrustedProxyHeader(t *testing.T) {
+	config := &Config{TrustedProxies: []string{"10.0.0.0/8"}, TrustedProxyHeader: "Forwarded"}
+	config.SetDefaults()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("Forwarded", `for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.2`)
+	r.Header.Set("X-Forwarded-For", "6.6.6.6")
+	assert.Equal(t, "2001:db8:cafe::17", ClientIP(config, r))
+
+	config = &Config{TrustedProxies: []string{"10.0.0.0/8"}, TrustedProxyHeader: "X-Real-IP"}
+	config.SetDefaults()
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Real-IP", "1.2.3.4")
+	r.Header.Set("X-Forwarded-For", "6.6.6.6")
+	assert.Equal(t, "1.2.3.4", ClientIP(config, r))
+}
+
+func TestMatchClientIP(t *testing.T) {
+	config := defaultConfig()
+	assert.True(t, matchClientIP(config, "1.2.3.4", "1.2.3.4"))
+	assert.False(t, matchClientIP(config, "1.2.3.4", "1.2.3.5"))
+
+	config.IPBinding = IPBindingSubnet
+	assert.True(t, matchClientIP(config, "1.2.3.4", "1.2.3.5"))
+	assert.False(t, matchClientIP(config, "1.2.3.4", "1.2.4.4"))
+	assert.True(t, matchClientIP(config, "2001:db8::1", "2001:db8::ffff"))
+	assert.False(t, matchClientIP(config, "2001:db8::1", "2001:db9::1"))
+	assert.False(t, matchClientIP(config, "1.2.3.4", "2001:db8::1"))
+
+	config.IPBinding = IPBindingOff
+	assert.True(t, matchClientIP(config, "1.2.3.4", "5.6.7.8"))
+}
@@ -1,22 +1,28 @@
 package auth
 
 import (
+	"net"
+	"net/http"
 	"os"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gocontrib/parse"
 	"github.com/gorilla/securecookie"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
 	defaultTokenKey    = "token"
 	defaultTokenCookie = "jwt_token"
+	defaultRealm       = "Restricted"
 )
 
 var (
 	defaultSingingMethod = jwt.SigningMethodHS256
 	defaultSecretKey     = securecookie.GenerateRandomKey(32)
+	defaultCSRFKey       = securecookie.GenerateRandomKey(32)
+	defaultLogMaskKey    = securecookie.GenerateRandomKey(32)
 )
 
 // Config defines options for authentication middleware.
@@ -31,6 +37,102 @@ type Config struct {
 	// TokenCookie specifies cookie name to extract from cookies
 	TokenCookie string
 
+	// TokenExtractors lists token sources in lookup order.
+	// Defaults to Authorization header, TokenCookie and TokenKey query parameter.
+	TokenExtractors []TokenExtractor
+
+	// DisableQueryToken disables tokens in query string to avoid leaking them
+	// into access logs and Referer headers
+	DisableQueryToken bool
+
+	// QueryTokenPaths restricts query string tokens to given path prefixes, e.g. downloads
+	QueryTokenPaths []string
+
+	// WebSocketProtocolPrefix specifies prefix of Sec-WebSocket-Protocol subprotocol with token, defaults to 'bearer.'
+	WebSocketProtocolPrefix string
+
+	// Cookie specifies token cookie attributes
+	Cookie CookieOptions
+
+	// LoginMode specifies whether login response sets token cookie
+	LoginMode LoginMode
+
+	// CSRF specifies protection of cookie-authenticated requests with unsafe methods
+	CSRF CSRFMode
+
+	// CSRFHeader specifies header to transport CSRF token, defaults to X-CSRF-Token
+	CSRFHeader string
+
+	// CSRFCookie specifies cookie name for double-submit CSRF token
+	CSRFCookie string
+
+	// CSRFKey is HMAC key to derive synchronizer CSRF tokens. By default it is derived from
+	// SecretKey with HKDF, or generated randomly if SecretKey is a function.
+	// Set it explicitly if several instances sign tokens with key functions.
+	CSRFKey []byte
+
+	// TrustedOrigins lists origins allowed by CSRFOrigin mode, defaults to request host
+	TrustedOrigins []string
+
+	// TrustedProxies lists IPs or CIDRs of proxies allowed to report client IP in TrustedProxyHeader
+	TrustedProxies []string
+	trustedProxies []*net.IPNet
+
+	// TrustedProxyHeader is the only header read from trusted proxies, defaults to X-Forwarded-For.
+	// Forwarded (RFC 7239), X-Real-IP or any header with comma-separated list of IPs can be used.
+	// Set it to the header your proxies overwrite or append to, other headers are passed from clients as is.
+	TrustedProxyHeader string
+
+	// IPBinding specifies how tokens are bound to client IP address
+	IPBinding IPBinding
+
+	// RateLimit limits login attempts, disabled if nil
+	RateLimit *RateLimitPolicy
+
+	// Lockout locks accounts after consecutive failed logins, disabled if nil
+	Lockout *LockoutPolicy
+
+	// WebSocket configures tickets and session checks of WebSocket connections
+	WebSocket *WebSocketPolicy
+
+	// Impersonation configures ImpersonateHandler and restrictions of impersonated sessions
+	Impersonation *ImpersonationPolicy
+
+	// EventSink receives authentication events for auditing
+	EventSink EventSink
+
+	// RequestID returns request ID attached to events, defaults to X-Request-ID header
+	RequestID func(r *http.Request) string
+
+	// Metrics receives authentication measurements
+	Metrics Metrics
+
+	// Logger receives redacted log messages, defaults to standard logrus logger
+	Logger Logger
+
+	// LogMaskKey is HMAC key to mask user names and emails in log fields. By default it is derived
+	// from SecretKey with HKDF, or generated randomly if SecretKey is a function.
+	LogMaskKey []byte
+
+	// LogLevels overrides log level per event, keyed by error code, EventType or Log* constant
+	LogLevels map[string]Level
+
+	// ErrorFormat selects JSON error format, legacy one by default
+	ErrorFormat ErrorFormat
+
+	// ProblemTypeBase prefixes problem type URI followed by lower-cased error code,
+	// about:blank is used if empty
+	ProblemTypeBase string
+
+	// ExposeErrorCause includes error causes in responses, never enable it in production
+	ExposeErrorCause bool
+
+	// ErrorHandler renders authentication failures instead of built-in formats
+	ErrorHandler ErrorHandler
+
+	// Messages localizes error messages and built-in pages, defaults to DefaultCatalog
+	Messages *Catalog
+
 	// SingingMethod specifies JWT signing method
 	SingingMethod jwt.SigningMethod
 
@@ -38,6 +140,12 @@ type Config struct {
 	SecretKey interface{}
 
 	TokenExpiration time.Duration
+
+	// Realm specifies protection space reported in WWW-Authenticate challenges
+	Realm string
+
+	// DisableBasicAuth rejects Basic HTTP authentication scheme in middleware
+	DisableBasicAuth bool
 }
 
 // Initializes default handlers if they omitted.
@@ -48,6 +156,29 @@ func (c *Config) SetDefaults() *Config {
 	if len(c.TokenCookie) == 0 {
 		c.TokenCookie = defaultTokenCookie
 	}
+	if c.TokenExtractors == nil {
+		c.TokenExtractors = []TokenExtractor{
+			AuthorizationToken,
+			CookieToken(""),
+			QueryToken(""),
+		}
+	}
+	if c.Logger == nil {
+		c.Logger = LogrusLogger(log.StandardLogger())
+	}
+	if c.RequestID == nil {
+		c.RequestID = defaultRequestID
+	}
+	c.trustedProxies = parseTrustedProxies(c, c.TrustedProxies)
+	if len(c.TrustedProxyHeader) == 0 {
+		c.TrustedProxyHeader = defaultTrustedProxyHeader
+	}
+	if c.Messages == nil {
+		c.Messages = DefaultCatalog
+	}
+	if c.Metrics == nil {
+		c.Metrics = nopMetrics{}
+	}
 	if c.SingingMethod == nil {
 		c.SingingMethod = defaultSingingMethod
 	}
@@ -59,8 +190,41 @@ func (c *Config) SetDefaults() *Config {
 			c.SecretKey = defaultSecretKey
 		}
 	}
+	if len(c.WebSocketProtocolPrefix) == 0 {
+		c.WebSocketProtocolPrefix = defaultWebSocketPrefix
+	}
+	c.Cookie.setDefaults()
+	if len(c.CSRFHeader) == 0 {
+		c.CSRFHeader = defaultCSRFHeader
+	}
+	if len(c.CSRFCookie) == 0 {
+		c.CSRFCookie = defaultCSRFCookie
+	}
+	if len(c.CSRFKey) == 0 {
+		if key, ok := c.SecretKey.([]byte); ok {
+			c.CSRFKey = deriveKey(key, csrfKeyInfo)
+		} else {
+			c.CSRFKey = defaultCSRFKey
+		}
+	}
+	if len(c.LogMaskKey) == 0 {
+		if key, ok := c.SecretKey.([]byte); ok {
+			c.LogMaskKey = deriveKey(key, logMaskKeyInfo)
+		} else {
+			c.LogMaskKey = defaultLogMaskKey
+		}
+	}
+	if c.Impersonation == nil {
+		c.Impersonation = &ImpersonationPolicy{}
+	}
+	if c.Impersonation.Revocations == nil {
+		c.Impersonation.Revocations = NewMemoryRevocationStore()
+	}
 	if c.TokenExpiration.Nanoseconds() == 0 {
 		c.TokenExpiration = parse.MustDuration("7d")
 	}
+	if len(c.Realm) == 0 {
+		c.Realm = defaultRealm
+	}
 	return c
 }
@@ -3,9 +3,47 @@ package auth
 import (
 	"context"
 	"net/http"
+	"time"
 )
 
-const userKey = "user"
+// contextKey is unexported type of context keys to avoid collisions with other packages.
+type contextKey int
+
+const (
+	userKey contextKey = iota
+	authInfoKey
+	webSocketProtocolKey
+)
+
+// AuthMethod specifies how request was authenticated.
+type AuthMethod string
+
+// Token methods are reported by TokenExtractor that found the token.
+const (
+	AuthMethodBasic AuthMethod = "basic"
+	// AuthMethodBearer is token in Authorization header
+	AuthMethodBearer AuthMethod = "bearer"
+	AuthMethodCookie AuthMethod = "cookie"
+	// AuthMethodAPIKey is token in custom header, see HeaderToken
+	AuthMethodAPIKey AuthMethod = "api_key"
+	AuthMethodQuery  AuthMethod = "query"
+	AuthMethodForm   AuthMethod = "form"
+	// AuthMethodWebSocketProtocol is token in Sec-WebSocket-Protocol header
+	AuthMethodWebSocketProtocol AuthMethod = "websocket_protocol"
+	AuthMethodTicket            AuthMethod = "ticket"
+)
+
+// AuthInfo describes how request was authenticated.
+type AuthInfo struct {
+	User User
+	// Token is parsed token, nil for basic auth
+	Token  *Token
+	Method AuthMethod
+	// AuthTime is when user has entered credentials, it is token issue time for token based methods
+	AuthTime time.Time
+	// Actor is admin impersonating the user, nil if request is not impersonated
+	Actor User
+}
 
 // GetRequestUser returns authenticated user for given request
 func GetRequestUser(r *http.Request) User {
@@ -14,14 +52,39 @@ func GetRequestUser(r *http.Request) User {
 
 // GetContextUser returns authenticated user if it presents in given context
 func GetContextUser(c context.Context) User {
-	var i = c.Value(userKey)
-	if i == nil {
-		return nil
+	user, _ := c.Value(userKey).(User)
+	return user
+}
+
+// MustUser returns authenticated user of given context, it panics if there is no user.
+func MustUser(c context.Context) User {
+	user := GetContextUser(c)
+	if user == nil {
+		panic("auth: no user in context")
 	}
-	return i.(User)
+	return user
 }
 
 // WithUser returns new context with given user
 func WithUser(parent context.Context, user User) context.Context {
 	return context.WithValue(parent, userKey, user)
 }
+
+// WithAuthInfo returns new context with given authentication info and its user
+func WithAuthInfo(parent context.Context, info *AuthInfo) context.Context {
+	return context.WithValue(WithUser(parent, info.User), authInfoKey, info)
+}
+
+// GetAuthInfo returns authentication info if it presents in given context
+func GetAuthInfo(c context.Context) *AuthInfo {
+	info, _ := c.Value(authInfoKey).(*AuthInfo)
+	return info
+}
+
+// getContextToken returns validated token of given context
+func getContextToken(c context.Context) *Token {
+	if info := GetAuthInfo(c); info != nil {
+		return info.Token
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build go1.18
+// +build go1.18
+
+package auth
+
+import "context"
+
+// UserAs returns authenticated user of given context as T.
+// Wrapped users like ChainUser are unwrapped until T is found.
+// It returns false if there is no user or it has another type.
+func UserAs[T User](c context.Context) (T, bool) {
+	user := GetContextUser(c)
+	for user != nil {
+		if t, ok := user.(T); ok {
+			return t, true
+		}
+		w, ok := user.(interface{ Unwrap() User })
+		if !ok {
+			break
+		}
+		user = w.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,32 @@
+//go:build go1.18
+// +build go1.18
+
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserAs(t *testing.T) {
+	_, ok := UserAs[*UserInfo](context.Background())
+	assert.False(t, ok)
+
+	ctx := WithUser(context.Background(), &UserInfo{ID: "1", Name: "bob"})
+	user, ok := UserAs[*UserInfo](ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "bob", user.Name)
+}
+
+func TestUserAs_Unwrap(t *testing.T) {
+	ctx := WithUser(context.Background(), &ChainUser{User: &UserInfo{ID: "1", Name: "bob"}, Store: "db"})
+	user, ok := UserAs[*UserInfo](ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "bob", user.Name)
+
+	chained, ok := UserAs[*ChainUser](ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "db:1", chained.GetID())
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetContextUser_ForeignValue(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user", "not a user")
+	assert.Nil(t, GetContextUser(ctx))
+	assert.Panics(t, func() { MustUser(ctx) })
+
+	user := &UserInfo{ID: "1", Name: "bob"}
+	ctx = WithUser(ctx, user)
+	assert.Equal(t, user, GetContextUser(ctx))
+	assert.Equal(t, user, MustUser(ctx))
+	assert.Equal(t, "not a user", ctx.Value("user"))
+}
+
+func authInfoHandler(config *Config) http.Handler {
+	return RequireUser(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := GetAuthInfo(r.Context())
+		fmt.Fprintf(w, "%s %s %v", info.User.GetName(), info.Method, info.Token != nil)
+	}))
+}
+
+func TestAuthInfo_Method(t *testing.T) {
+	config := makeTestConfig()
+	c := &C{T: t, config: config}
+	token := c.makeToken("bob", "b0b")
+	h := authInfoHandler(config)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.SetBasicAuth("bob", "b0b")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "bob basic false", w.Body.String())
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(authorizationHeader, "Bearer "+token)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "bob bearer true", w.Body.String())
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: config.TokenCookie, Value: token})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "bob cookie true", w.Body.String())
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LoginMode specifies how login response delivers token to client.
+type LoginMode int
+
+const (
+	// LoginBody returns token in JSON body only
+	LoginBody LoginMode = iota
+	// LoginCookie returns token in JSON body and sets token cookie
+	LoginCookie
+	// LoginCookieOnly sets token cookie and omits token from JSON body, suitable for browser clients
+	LoginCookieOnly
+)
+
+const (
+	hostCookiePrefix   = "__Host-"
+	secureCookiePrefix = "__Secure-"
+)
+
+// CookieOptions defines attributes of token cookie, omitted fields get defaults individually.
+type CookieOptions struct {
+	// Path of cookie, defaults to /
+	Path   string
+	Domain string
+	// MaxAge of cookie, zero means token expiration, negative means session cookie
+	MaxAge time.Duration
+	Secure bool
+	// DisableHttpOnly exposes token cookie to client scripts
+	DisableHttpOnly bool
+	// SameSite defaults to Lax mode
+	SameSite http.SameSite
+}
+
+func (o *CookieOptions) setDefaults() {
+	if len(o.Path) == 0 {
+		o.Path = "/"
+	}
+	if o.SameSite == 0 {
+		o.SameSite = http.SameSiteLaxMode
+	}
+}
+
+// SetTokenCookie sets token cookie with attributes from Config.Cookie.
+func SetTokenCookie(w http.ResponseWriter, config *Config, token string, expiredAt time.Time) {
+	cookie := makeTokenCookie(config, token)
+	switch {
+	case config.Cookie.MaxAge > 0:
+		cookie.MaxAge = int(config.Cookie.MaxAge.Seconds())
+		cookie.Expires = now().Add(config.Cookie.MaxAge)
+	case config.Cookie.MaxAge == 0:
+		cookie.MaxAge = int(expiredAt.Sub(now()).Seconds())
+		cookie.Expires = expiredAt
+	}
+	http.SetCookie(w, cookie)
+}
+
+// ClearTokenCookie expires token cookie.
+func ClearTokenCookie(w http.ResponseWriter, config *Config) {
+	cookie := makeTokenCookie(config, "")
+	cookie.MaxAge = -1
+	cookie.Expires = time.Unix(0, 0)
+	http.SetCookie(w, cookie)
+}
+
+// makeTokenCookie applies cookie attributes and __Host-/__Secure- prefix rules.
+func makeTokenCookie(config *Config, value string) *http.Cookie {
+	opts := config.Cookie
+	cookie := &http.Cookie{
+		Name:     config.TokenCookie,
+		Value:    value,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		Secure:   opts.Secure,
+		HttpOnly: !opts.DisableHttpOnly,
+		SameSite: opts.SameSite,
+	}
+	if strings.HasPrefix(cookie.Name, secureCookiePrefix) {
+		cookie.Secure = true
+	}
+	if strings.HasPrefix(cookie.Name, hostCookiePrefix) {
+		cookie.Secure = true
+		cookie.Path = "/"
+		cookie.Domain = ""
+	}
+	// browsers reject SameSite=None without Secure attribute
+	if cookie.SameSite == http.SameSiteNoneMode {
+		cookie.Secure = true
+	}
+	return cookie
+}
+
+// LogoutHandler expires token cookie and double-submit CSRF cookie.
+func LogoutHandler(config *Config) http.Handler {
+	return LogoutHandlerFunc(config)
+}
+
+func LogoutHandlerFunc(config *Config) http.HandlerFunc {
+	config = config.SetDefaults()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ClearTokenCookie(w, config)
+		if _, err := r.Cookie(config.CSRFCookie); err == nil {
+			clearCSRFCookie(w, r, config)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"golang.org/x/crypto/hkdf"
+)
+
+// CSRFMode specifies cross-site request forgery defence for cookie-authenticated requests.
+type CSRFMode int
+
+const (
+	// CSRFNone disables CSRF checks
+	CSRFNone CSRFMode = iota
+	// CSRFDoubleSubmit requires CSRF header to match value of CSRF cookie
+	CSRFDoubleSubmit
+	// CSRFSynchronizer requires CSRF header to match token bound to the session token
+	CSRFSynchronizer
+	// CSRFOrigin requires Origin or Referer header to match trusted origins
+	CSRFOrigin
+)
+
+const (
+	defaultCSRFHeader = "X-CSRF-Token"
+	defaultCSRFCookie = "csrf_token"
+)
+
+// CSRFToken returns CSRF token to be sent back by client in Config.CSRFHeader.
+// It returns empty string if the request is not authenticated by cookie.
+func CSRFToken(config *Config, r *http.Request) string {
+	switch config.CSRF {
+	case CSRFDoubleSubmit:
+		cookie, err := r.Cookie(config.CSRFCookie)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	case CSRFSynchronizer:
+		cookie, err := r.Cookie(config.TokenCookie)
+		if err != nil {
+			return ""
+		}
+		return synchronizerToken(config, cookie.Value)
+	default:
+		return ""
+	}
+}
+
+// csrfKeyInfo binds key derived from SecretKey to CSRF tokens.
+const csrfKeyInfo = "gocontrib/auth csrf"
+
+// deriveKey derives independent 32-byte key for given purpose from secret,
+// so tokens of one purpose never reveal or forge tokens of another one.
+func deriveKey(secret []byte, info string) []byte {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(info)), key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// SetCSRFCookie issues new double-submit CSRF cookie readable by client scripts.
+func SetCSRFCookie(w http.ResponseWriter, r *http.Request, config *Config) string {
+	token := base64.RawURLEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+	http.SetCookie(w, makeCSRFCookie(r, config, token))
+	return token
+}
+
+func clearCSRFCookie(w http.ResponseWriter, r *http.Request, config *Config) {
+	cookie := makeCSRFCookie(r, config, "")
+	cookie.MaxAge = -1
+	cookie.Expires = time.Unix(0, 0)
+	http.SetCookie(w, cookie)
+}
+
+func makeCSRFCookie(r *http.Request, config *Config, value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     config.CSRFCookie,
+		Value:    value,
+		Path:     "/",
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// checkCSRF validates unsafe requests authenticated by given cookie token.
+func checkCSRF(config *Config, w http.ResponseWriter, r *http.Request, token string) *Error {
+	if config.CSRF == CSRFNone {
+		return nil
+	}
+
+	if isSafeMethod(r.Method) {
+		if config.CSRF == CSRFDoubleSubmit {
+			if _, err := r.Cookie(config.CSRFCookie); err != nil {
+				SetCSRFCookie(w, r, config)
+			}
+		}
+		return nil
+	}
+
+	switch config.CSRF {
+	case CSRFDoubleSubmit:
+		cookie, err := r.Cookie(config.CSRFCookie)
+		if err != nil || !compareCSRF(r.Header.Get(config.CSRFHeader), cookie.Value) {
+			return ErrCSRF
+		}
+	case CSRFSynchronizer:
+		expected := synchronizerToken(config, token)
+		if !compareCSRF(r.Header.Get(config.CSRFHeader), expected) {
+			return ErrCSRF
+		}
+	case CSRFOrigin:
+		if !isTrustedOrigin(config, r) {
+			return ErrCSRF
+		}
+	}
+
+	return nil
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+func compareCSRF(actual, expected string) bool {
+	if len(actual) == 0 || len(expected) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) == 1
+}
+
+func synchronizerToken(config *Config, sessionToken string) string {
+	mac := hmac.New(sha256.New, config.CSRFKey)
+	mac.Write([]byte(sessionToken))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func isTrustedOrigin(config *Config, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 || origin == "null" {
+		origin = r.Header.Get("Referer")
+	}
+	if len(origin) == 0 {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil || len(u.Host) == 0 {
+		return false
+	}
+
+	if len(config.TrustedOrigins) == 0 {
+		return strings.EqualFold(u.Host, r.Host)
+	}
+
+	origin = u.Scheme + "://" + u.Host
+	for _, trusted := range config.TrustedOrigins {
+		if strings.EqualFold(strings.TrimRight(trusted, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRF_HeaderTokenExempt(t *testing.T) {
+	config := makeTestConfig()
+	config.CSRF = CSRFSynchronizer
+	c := makectx(t, config, middlewareServer(config))
+	token := c.makeToken("bob", "b0b")
+
+	c.expect.POST("/form").
+		WithHeader(authorizationHeader, fmt.Sprintf("%s %s", schemeBearer, token)).
+		Expect().
+		Status(http.StatusOK)
+
+	// token source is known from extractor, not guessed by cookie values
+	config.TokenExtractors = []TokenExtractor{HeaderToken("X-Auth-Token"), CookieToken("")}
+	c.expect.POST("/form").
+		WithHeader("X-Auth-Token", token).
+		WithCookie(defaultTokenCookie, token).
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestCSRF_InvalidTokenUnauthorized(t *testing.T) {
+	config := makeTestConfig()
+	config.CSRF = CSRFSynchronizer
+	c := makectx(t, config, middlewareServer(config))
+
+	c.expect.POST("/form").
+		WithCookie(defaultTokenCookie, "garbage").
+		Expect().
+		Status(http.StatusUnauthorized)
+}
+
+func TestCSRF_KeyDerivedFromSecret(t *testing.T) {
+	config := (&Config{SecretKey: []byte("secret")}).SetDefaults()
+	assert.Len(t, config.CSRFKey, 32)
+	assert.NotEqual(t, config.SecretKey, config.CSRFKey)
+	assert.Equal(t, config.CSRFKey, (&Config{SecretKey: []byte("secret")}).SetDefaults().CSRFKey)
+
+	config = (&Config{SecretKey: []byte("secret"), CSRFKey: []byte("csrf")}).SetDefaults()
+	assert.Equal(t, []byte("csrf"), config.CSRFKey)
+}
+
+func TestCSRF_Synchronizer(t *testing.T) {
+	config := makeTestConfig()
+	config.CSRF = CSRFSynchronizer
+	c := makectx(t, config, middlewareServer(config))
+	token := c.makeToken("bob", "b0b")
+
+	c.expect.GET("/data").
+		WithCookie(defaultTokenCookie, token).
+		Expect().
+		Status(http.StatusOK)
+
+	c.expect.POST("/form").
+		WithCookie(defaultTokenCookie, token).
+		Expect().
+		Status(http.StatusForbidden)
+
+	c.expect.POST("/form").
+		WithCookie(defaultTokenCookie, token).
+		WithHeader(defaultCSRFHeader, "invalid").
+		Expect().
+		Status(http.StatusForbidden)
+
+	c.expect.POST("/form").
+		WithCookie(defaultTokenCookie, token).
+		WithHeader(defaultCSRFHeader, synchronizerToken(config, token)).
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestCSRF_DoubleSubmit(t *testing.T) {
+	config := makeTestConfig()
+	config.CSRF = CSRFDoubleSubmit
+	c := makectx(t, config, middlewareServer(config))
+	token := c.makeToken("bob", "b0b")
+
+	csrf := c.expect.GET("/data").
+		WithCookie(defaultTokenCookie, token).
+		Expect().
+		Status(http.StatusOK).
+		Cookie(defaultCSRFCookie).
+		Value().
+		Raw()
+
+	c.expect.POST("/form").
+		WithCookie(defaultTokenCookie, token).
+		WithCookie(defaultCSRFCookie, csrf).
+		Expect().
+		Status(http.StatusForbidden)
+
+	c.expect.POST("/form").
+		WithCookie(defaultTokenCookie, token).
+		WithCookie(defaultCSRFCookie, csrf).
+		WithHeader(defaultCSRFHeader, csrf).
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestCSRF_Origin(t *testing.T) {
+	config := makeTestConfig()
+	config.CSRF = CSRFOrigin
+	config.TrustedOrigins = []string{"https://app.test.net"}
+	c := makectx(t, config, middlewareServer(config))
+	token := c.makeToken("bob", "b0b")
+
+	c.expect.POST("/form").
+		WithCookie(defaultTokenCookie, token).
+		Expect().
+		Status(http.StatusForbidden)
+
+	c.expect.POST("/form").
+		WithCookie(defaultTokenCookie, token).
+		WithHeader("Origin", "https://evil.test.net").
+		Expect().
+		Status(http.StatusForbidden)
+
+	c.expect.POST("/form").
+		WithCookie(defaultTokenCookie, token).
+		WithHeader("Referer", "https://app.test.net/page").
+		Expect().
+		Status(http.StatusOK)
+}
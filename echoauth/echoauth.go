@@ -0,0 +1,86 @@
+// Package echoauth adapts auth middleware and handlers to echo framework.
+package echoauth
+
+import (
+	"net/http"
+
+	"github.com/gocontrib/auth"
+	"github.com/gocontrib/auth/internal/adapter"
+	"github.com/labstack/echo/v4"
+)
+
+// UserKey is echo context key of authenticated user.
+const UserKey = adapter.UserKey
+
+// RequireUser creates echo middleware that authenticates requests with given configuration.
+func RequireUser(config *auth.Config) echo.MiddlewareFunc {
+	return wrap(auth.RequireUser(config))
+}
+
+// RequireAdmin creates echo middleware that authenticates only admin users.
+func RequireAdmin(config *auth.Config) echo.MiddlewareFunc {
+	return wrap(auth.RequireAdmin(config))
+}
+
+func wrap(middleware func(http.Handler) http.Handler) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var err error
+			h := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				if user := auth.GetContextUser(r.Context()); user != nil {
+					c.Set(UserKey, user)
+				}
+				err = next(c)
+			}))
+			h.ServeHTTP(c.Response(), c.Request())
+			return err
+		}
+	}
+}
+
+// LoginHandler wraps auth.LoginHandler.
+func LoginHandler(config *auth.Config) echo.HandlerFunc {
+	return echo.WrapHandler(auth.LoginHandler(config))
+}
+
+// RegisterHandler wraps auth.RegisterHandler.
+func RegisterHandler(config *auth.Config) echo.HandlerFunc {
+	return echo.WrapHandler(auth.RegisterHandler(config))
+}
+
+// CheckTokenHandler wraps auth.CheckTokenHandler.
+func CheckTokenHandler(config *auth.Config) echo.HandlerFunc {
+	return echo.WrapHandler(auth.CheckTokenHandler(config))
+}
+
+// GetUser returns authenticated user of given echo context.
+func GetUser(c echo.Context) auth.User {
+	if user, ok := c.Get(UserKey).(auth.User); ok {
+		return user
+	}
+	if c.Request() == nil {
+		return nil
+	}
+	return auth.GetContextUser(c.Request().Context())
+}
+
+// Routes is implemented by echo.Echo and echo.Group.
+type Routes interface {
+	GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+}
+
+// Router adapts echo routes to oauth.Router.
+type Router struct {
+	routes Routes
+}
+
+// NewRouter creates oauth.Router registering routes in given echo router.
+func NewRouter(routes Routes) *Router {
+	return &Router{routes: routes}
+}
+
+// Get registers handler for chi-style pattern like /api/oauth/login/{provider}.
+func (r *Router) Get(pattern string, h http.HandlerFunc) {
+	r.routes.GET(adapter.ConvertPattern(pattern), echo.WrapHandler(h))
+}
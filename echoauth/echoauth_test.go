@@ -0,0 +1,117 @@
+package echoauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gocontrib/auth"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type testStore map[string]*auth.UserInfo
+
+func (s testStore) ValidateCredentials(ctx context.Context, username, password string) (auth.User, error) {
+	u, ok := s[username]
+	if !ok || u.Pwd != password {
+		return nil, errors.New("invalid credentials")
+	}
+	return u, nil
+}
+
+func (s testStore) FindUserByID(ctx context.Context, userID string) (auth.User, error) {
+	for _, u := range s {
+		if u.ID == userID {
+			return u, nil
+		}
+	}
+	return nil, errors.New("user not found")
+}
+
+func (s testStore) Close() {}
+
+func makeServer() *echo.Echo {
+	config := &auth.Config{
+		UserStore: testStore{
+			"bob":   {ID: "1", Name: "bob", Pwd: "b0b"},
+			"admin": {ID: "2", Name: "admin", Pwd: "admin", Admin: true},
+		},
+	}
+
+	e := echo.New()
+	e.POST("/api/login", LoginHandler(config))
+	e.GET("/api/token", CheckTokenHandler(config))
+
+	handler := func(c echo.Context) error {
+		return c.String(http.StatusOK, GetUser(c).GetName()+" "+auth.GetContextUser(c.Request().Context()).GetName())
+	}
+	e.GET("/data", handler, RequireUser(config))
+	e.GET("/admin/data", handler, RequireAdmin(config))
+	return e
+}
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRequireUser(t *testing.T) {
+	s := makeServer()
+
+	w := serve(s, httptest.NewRequest("GET", "/data", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	r := httptest.NewRequest("GET", "/data", nil)
+	r.SetBasicAuth("bob", "b0b")
+	w = serve(s, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bob bob", w.Body.String())
+}
+
+func TestRequireAdmin(t *testing.T) {
+	s := makeServer()
+
+	r := httptest.NewRequest("GET", "/admin/data", nil)
+	r.SetBasicAuth("bob", "b0b")
+	w := serve(s, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotContains(t, w.Body.String(), "bob bob")
+
+	r = httptest.NewRequest("GET", "/admin/data", nil)
+	r.SetBasicAuth("admin", "admin")
+	w = serve(s, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "admin admin", w.Body.String())
+}
+
+func TestLoginAndCheckToken(t *testing.T) {
+	s := makeServer()
+
+	r := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"username":"bob","password":"b0b"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := serve(s, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	res := &auth.LoginResponse{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), res))
+	assert.NotEmpty(t, res.Token)
+
+	r = httptest.NewRequest("GET", "/api/token", nil)
+	r.Header.Set("Authorization", "Bearer "+res.Token)
+	w = serve(s, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	r = httptest.NewRequest("GET", "/data", nil)
+	r.Header.Set("Authorization", "Bearer "+res.Token)
+	w = serve(s, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bob bob", w.Body.String())
+
+	w = serve(s, httptest.NewRequest("GET", "/api/token", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -1,98 +1,238 @@
 package auth
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
+	"sync"
+	"time"
 )
 
+// Error is authentication failure with unique code and default HTTP status.
+// Errors derived by With* methods match their origin in errors.Is.
 type Error struct {
 	Code    string `json:"error_code,omitempty"`
 	Message string `json:"error_message,omitempty"`
 	Status  int    `json:"status"`
-	Cause   error  `json:"cause,omitempty"`
+	Cause   error  `json:"-"` // exposed only if Config.ExposeErrorCause is set
+
+	// BearerError is RFC 6750 error code reported in WWW-Authenticate challenge
+	BearerError string `json:"-"`
+	// Challenges to send in WWW-Authenticate headers
+	Challenges []string `json:"-"`
+	// RetryAfter is sent in Retry-After header
+	RetryAfter time.Duration `json:"-"`
 }
 
 func (err *Error) Error() string {
+	if err.Cause != nil {
+		return err.Message + ": " + err.Cause.Error()
+	}
 	return err.Message
 }
 
+// ErrorHandler renders authentication failure.
+// WWW-Authenticate and Retry-After headers are already set when it is called.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err *Error)
+
+// Unwrap returns cause of the error.
+func (err *Error) Unwrap() error {
+	return err.Cause
+}
+
+// Is reports whether target is *Error with the same code.
+func (err *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t != nil && t.Code == err.Code
+}
+
 func (err *Error) WithCause(cause error) *Error {
-	return &Error{
-		Code:    err.Code,
-		Message: err.Message,
-		Status:  err.Status,
-		Cause:   cause,
+	result := *err
+	result.Cause = cause
+	return &result
+}
+
+// WithChallenges returns copy of the error with given WWW-Authenticate challenges.
+func (err *Error) WithChallenges(challenges ...string) *Error {
+	result := *err
+	result.Challenges = challenges
+	return &result
+}
+
+// WithRetryAfter returns copy of the error with given Retry-After interval.
+func (err *Error) WithRetryAfter(d time.Duration) *Error {
+	result := *err
+	result.RetryAfter = d
+	return &result
+}
+
+var (
+	errorRegistryLock sync.RWMutex
+	errorRegistry     = make(map[string]*Error)
+)
+
+// RegisterError adds error to registry of codes, it panics if code is already registered.
+func RegisterError(err *Error) *Error {
+	errorRegistryLock.Lock()
+	defer errorRegistryLock.Unlock()
+	if _, ok := errorRegistry[err.Code]; ok {
+		panic(fmt.Sprintf("auth: error code %s is already registered", err.Code))
 	}
+	errorRegistry[err.Code] = err
+	return err
+}
+
+// NewError registers new error code with default HTTP status and message.
+func NewError(code string, status int, message string) *Error {
+	return RegisterError(&Error{
+		Code:    code,
+		Status:  status,
+		Message: message,
+	})
+}
+
+// LookupError returns registered error by code.
+func LookupError(code string) (*Error, bool) {
+	errorRegistryLock.RLock()
+	defer errorRegistryLock.RUnlock()
+	err, ok := errorRegistry[code]
+	return err, ok
+}
+
+// ErrorCodes returns sorted list of registered error codes.
+func ErrorCodes() []string {
+	errorRegistryLock.RLock()
+	defer errorRegistryLock.RUnlock()
+	codes := make([]string, 0, len(errorRegistry))
+	for code := range errorRegistry {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
 }
 
 var (
-	ErrBadAuthorizationHeader = &Error{
+	ErrBadAuthorizationHeader = RegisterError(&Error{
 		Code:    "AUTH-BAD-AUTHORIZATION-HEADER",
 		Status:  http.StatusUnauthorized,
 		Message: "Invalid authorization header",
-	}
-	ErrUnsupportedAuthScheme = &Error{
+	})
+	ErrUnsupportedAuthScheme = RegisterError(&Error{
 		Code:    "AUTH-UNSUPPORTED-SCHEME",
 		Status:  http.StatusUnauthorized,
 		Message: "Unsupported authentication scheme",
-	}
-	ErrInvalidToken = &Error{
-		Code:    "AUTH-INVALID-TOKEN",
-		Status:  http.StatusUnauthorized,
-		Message: "User token is invalid, please re-authenticate",
-	}
-	ErrMissingUserID = &Error{
-		Code:    "AUTH-INVALID-TOKEN",
-		Status:  http.StatusUnauthorized,
-		Message: "User token is missing user_id field",
-	}
-	ErrMissingExp = &Error{
-		Code:    "AUTH-INVALID-TOKEN",
+	})
+	ErrInvalidToken = RegisterError(&Error{
+		Code:        "AUTH-INVALID-TOKEN",
+		Status:      http.StatusUnauthorized,
+		Message:     "User token is invalid, please re-authenticate",
+		BearerError: bearerInvalidToken,
+	})
+	ErrMissingUserID = RegisterError(&Error{
+		Code:        "AUTH-MISSING-USER-ID",
+		Status:      http.StatusUnauthorized,
+		Message:     "User token is missing user_id field",
+		BearerError: bearerInvalidToken,
+	})
+	ErrMissingExp = RegisterError(&Error{
+		Code:        "AUTH-MISSING-EXP",
+		Status:      http.StatusUnauthorized,
+		Message:     "User token is missing exp field",
+		BearerError: bearerInvalidToken,
+	})
+	ErrInvalidIssuer = RegisterError(&Error{
+		Code:        "AUTH-INVALID-ISSUER",
+		Status:      http.StatusUnauthorized,
+		Message:     "User token was issued from another host",
+		BearerError: bearerInvalidToken,
+	})
+	ErrInvalidClientIP = RegisterError(&Error{
+		Code:        "AUTH-INVALID-CLIENT-IP",
+		Status:      http.StatusUnauthorized,
+		Message:     "User token was issued for another IP address",
+		BearerError: bearerInvalidToken,
+	})
+	ErrNotAdmin = RegisterError(&Error{
+		Code:        "AUTH-NOT-ADMIN",
+		Status:      http.StatusForbidden,
+		Message:     "You need admin privileges to make this API call",
+		BearerError: bearerInsufficientScope,
+	})
+	ErrCSRF = RegisterError(&Error{
+		Code:    "AUTH-CSRF-FAILED",
+		Status:  http.StatusForbidden,
+		Message: "CSRF token is missing or invalid",
+	})
+	ErrTooManyRequests = RegisterError(&Error{
+		Code:    "AUTH-TOO-MANY-REQUESTS",
+		Status:  http.StatusTooManyRequests,
+		Message: "Too many login attempts, please try again later",
+	})
+	ErrAccountLocked = RegisterError(&Error{
+		Code:    "AUTH-ACCOUNT-LOCKED",
 		Status:  http.StatusUnauthorized,
-		Message: "User token is missing exp field",
-	}
-	ErrInvalidIssuer = &Error{
-		Code:    "AUTH-INVALID-ISSUER",
+		Message: "Too many failed login attempts, account is temporarily locked",
+	})
+	ErrInvalidTicket = RegisterError(&Error{
+		Code:        "AUTH-INVALID-TICKET",
+		Status:      http.StatusUnauthorized,
+		Message:     "WebSocket ticket is invalid or expired",
+		BearerError: bearerInvalidToken,
+	})
+	ErrSessionExpired = RegisterError(&Error{
+		Code:    "AUTH-SESSION-EXPIRED",
 		Status:  http.StatusUnauthorized,
-		Message: "User token was issued from another host",
-	}
-	ErrInvalidClientIP = &Error{
-		Code:    "AUTH-INVALID-CLIENT-IP",
+		Message: "Session has expired, please re-authenticate",
+	})
+	ErrSessionRevoked = RegisterError(&Error{
+		Code:    "AUTH-SESSION-REVOKED",
 		Status:  http.StatusUnauthorized,
-		Message: "User token was issued for another IP address",
-	}
-	ErrNotAdmin = &Error{
-		Code:    "AUTH-NOT-ADMIN",
+		Message: "Session has been revoked",
+	})
+	ErrImpersonationForbidden = RegisterError(&Error{
+		Code:    "AUTH-IMPERSONATION-FORBIDDEN",
 		Status:  http.StatusForbidden,
-		Message: "You need admin privileges to make this API call",
-	}
-	ErrMalformedContent = &Error{
+		Message: "You are not allowed to impersonate this user",
+	})
+	ErrImpersonationNested = RegisterError(&Error{
+		Code:    "AUTH-IMPERSONATION-NESTED",
+		Status:  http.StatusForbidden,
+		Message: "Impersonation cannot be nested",
+	})
+	ErrImpersonationRestricted = RegisterError(&Error{
+		Code:    "AUTH-IMPERSONATION-RESTRICTED",
+		Status:  http.StatusForbidden,
+		Message: "This API call is not allowed while impersonating",
+	})
+	ErrMalformedContent = RegisterError(&Error{
 		Code:    "AUTH-BAD-CONTENT",
 		Status:  http.StatusBadRequest,
 		Message: "Malformed content",
-	}
-	ErrBadCredentials = &Error{
+	})
+	ErrBadCredentials = RegisterError(&Error{
 		Code:    "AUTH-BAD-CREDENTIALS",
 		Status:  http.StatusUnauthorized,
 		Message: "Invalid user credentials",
-	}
-	ErrUserNotFound = &Error{
-		Code:    "AUTH-USER-NOT-FOUND",
-		Status:  http.StatusUnauthorized,
-		Message: "User not found",
-	}
-	ErrUnsupportedContentType = &Error{
+	})
+	ErrUserNotFound = RegisterError(&Error{
+		Code:        "AUTH-USER-NOT-FOUND",
+		Status:      http.StatusUnauthorized,
+		Message:     "User not found",
+		BearerError: bearerInvalidToken,
+	})
+	ErrUnsupportedContentType = RegisterError(&Error{
 		Code:    "AUTH-UNSUPPORTED-CONTENT-TYPE",
 		Status:  http.StatusUnsupportedMediaType,
 		Message: "Unrecognized data format",
-	}
-	ErrEncodeTokenFailed = &Error{
+	})
+	ErrEncodeTokenFailed = RegisterError(&Error{
 		Code:    "AUTH-ENCODE-TOKEN-FAILED",
 		Status:  http.StatusUnauthorized,
 		Message: "Cannot encode user token",
-	}
-	ErrBadState = &Error{
+	})
+	ErrBadState = RegisterError(&Error{
 		Code:    "AUTH-INTERNAL-SERVER-ERROR",
 		Status:  http.StatusInternalServerError,
 		Message: "Internal server error",
-	}
+	})
 )
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError_IsAndUnwrap(t *testing.T) {
+	cause := errors.New("signature is invalid")
+	err := ErrInvalidToken.WithCause(cause).WithChallenges("Bearer")
+
+	assert.True(t, errors.Is(err, ErrInvalidToken))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, ErrMissingUserID))
+	assert.Equal(t, "User token is invalid, please re-authenticate: signature is invalid", err.Error())
+
+	var wrapped error = err
+	var authErr *Error
+	assert.True(t, errors.As(wrapped, &authErr))
+	assert.Equal(t, ErrInvalidToken.Code, authErr.Code)
+}
+
+func TestError_Registry(t *testing.T) {
+	codes := ErrorCodes()
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		assert.False(t, seen[code], code)
+		seen[code] = true
+	}
+	assert.True(t, seen[ErrMissingExp.Code])
+
+	e, ok := LookupError("AUTH-MISSING-USER-ID")
+	assert.True(t, ok)
+	assert.Equal(t, ErrMissingUserID, e)
+
+	custom := NewError("TEST-CUSTOM", http.StatusTeapot, "Custom")
+	e, ok = LookupError("TEST-CUSTOM")
+	assert.True(t, ok)
+	assert.Equal(t, custom, e)
+
+	assert.Panics(t, func() {
+		NewError(ErrNotAdmin.Code, http.StatusForbidden, "duplicate")
+	})
+}
+
+func TestErrorHandler(t *testing.T) {
+	config := makeTestConfig()
+	config.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err *Error) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte(err.Code))
+	}
+	c := makectx(t, config, middlewareServer(config))
+	r := c.expect.GET("/data").
+		Expect().
+		Status(http.StatusTeapot)
+	r.Body().Equal(ErrBadAuthorizationHeader.Code)
+	r.Header("WWW-Authenticate").NotEmpty()
+}
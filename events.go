@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// EventType identifies authentication event.
+type EventType string
+
+const (
+	EventLoginSuccess  EventType = "login_success"
+	EventLoginFailure  EventType = "login_failure"
+	EventTokenIssued   EventType = "token_issued"
+	EventTokenRejected EventType = "token_rejected"
+	EventAdminDenied   EventType = "admin_denied"
+	EventRegistration  EventType = "registration"
+	EventOAuthLink     EventType = "oauth_link"
+
+	EventImpersonationStart EventType = "impersonation_start"
+	EventImpersonationEnd   EventType = "impersonation_end"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// Event describes authentication event for auditing.
+type Event struct {
+	Type      EventType `json:"type"`
+	UserID    string    `json:"user_id,omitempty"`
+	UserName  string    `json:"user_name,omitempty"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Time      time.Time `json:"time"`
+	// Reason is error code of failure
+	Reason string `json:"reason,omitempty"`
+	// Provider is OAuth provider name
+	Provider string `json:"provider,omitempty"`
+	// ActorID and ActorName identify admin impersonating the user
+	ActorID   string `json:"actor_id,omitempty"`
+	ActorName string `json:"actor_name,omitempty"`
+}
+
+// EventSink receives authentication events.
+type EventSink interface {
+	Emit(ctx context.Context, event Event)
+}
+
+// EventSinkFunc is an adapter to use ordinary function as EventSink.
+type EventSinkFunc func(ctx context.Context, event Event)
+
+// Emit calls f(ctx, event).
+func (f EventSinkFunc) Emit(ctx context.Context, event Event) {
+	f(ctx, event)
+}
+
+// EmitEvent fills request details and sends event to Config.EventSink.
+func EmitEvent(config *Config, r *http.Request, event Event) {
+	if config.EventSink == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = now()
+	}
+	event.ClientIP = getClientIP(config, r)
+	event.UserAgent = r.UserAgent()
+	event.RequestID = config.RequestID(r)
+	config.EventSink.Emit(r.Context(), event)
+}
+
+func emitUserEvent(config *Config, r *http.Request, eventType EventType, user User) {
+	if config.EventSink == nil {
+		return
+	}
+	EmitEvent(config, r, Event{
+		Type:     eventType,
+		UserID:   user.GetID(),
+		UserName: user.GetName(),
+	})
+}
+
+func emitFailureEvent(config *Config, r *http.Request, eventType EventType, username string, err *Error) {
+	if config.EventSink == nil {
+		return
+	}
+	EmitEvent(config, r, Event{
+		Type:     eventType,
+		UserName: username,
+		Reason:   err.Code,
+	})
+}
+
+// emitTokenRejected emits token rejection attributed to the token user if token was parsed.
+func emitTokenRejected(config *Config, r *http.Request, token *Token, err *Error) {
+	if config.EventSink == nil {
+		return
+	}
+	event := Event{
+		Type:   EventTokenRejected,
+		Reason: err.Code,
+	}
+	if token != nil {
+		event.UserID = token.UserID
+		event.UserName = token.UserName
+		if token.Actor != nil {
+			event.ActorID = token.Actor.UserID
+			event.ActorName = token.Actor.UserName
+		}
+	}
+	EmitEvent(config, r, event)
+}
+
+func defaultRequestID(r *http.Request) string {
+	return r.Header.Get(requestIDHeader)
+}
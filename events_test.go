@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type eventRecorder struct {
+	sync.Mutex
+	events []Event
+}
+
+func (rec *eventRecorder) Emit(ctx context.Context, event Event) {
+	rec.Lock()
+	defer rec.Unlock()
+	rec.events = append(rec.events, event)
+}
+
+func (rec *eventRecorder) types() []EventType {
+	rec.Lock()
+	defer rec.Unlock()
+	var result []EventType
+	for _, e := range rec.events {
+		result = append(result, e.Type)
+	}
+	return result
+}
+
+func TestEvents_Login(t *testing.T) {
+	rec := &eventRecorder{}
+	config := makeTestConfig()
+	config.EventSink = rec
+	c := makectx(t, config, httptest.NewServer(LoginHandler(config)))
+
+	c.expect.POST("/").
+		WithHeader(requestIDHeader, "req-1").
+		WithHeader("User-Agent", "test-agent").
+		WithJSON(&Credentials{UserName: "bob", Password: "1"}).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	c.expect.POST("/").
+		WithJSON(&Credentials{UserName: "bob", Password: "b0b"}).
+		Expect().
+		Status(http.StatusOK)
+
+	assert.Equal(t, []EventType{EventLoginFailure, EventLoginSuccess, EventTokenIssued}, rec.types())
+
+	failure := rec.events[0]
+	assert.Equal(t, "bob", failure.UserName)
+	assert.Equal(t, ErrBadCredentials.Code, failure.Reason)
+	assert.Equal(t, "req-1", failure.RequestID)
+	assert.Equal(t, "test-agent", failure.UserAgent)
+	assert.NotEmpty(t, failure.ClientIP)
+	assert.False(t, failure.Time.IsZero())
+
+	assert.NotEmpty(t, rec.events[1].UserID)
+}
+
+func TestEvents_Middleware(t *testing.T) {
+	rec := &eventRecorder{}
+	config := makeTestConfig()
+	config.EventSink = rec
+	c := makectx(t, config, middlewareServer(config))
+
+	c.expect.GET("/data").
+		WithHeader(authorizationHeader, "Bearer invalid").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	c.expect.GET("/admin/data").
+		WithBasicAuth("bob", "b0b").
+		Expect().
+		Status(http.StatusForbidden)
+
+	c.expect.GET("/data").
+		WithBasicAuth("bob", "b0b").
+		Expect().
+		Status(http.StatusOK)
+
+	assert.Equal(t, []EventType{EventTokenRejected, EventLoginSuccess, EventAdminDenied, EventLoginSuccess}, rec.types())
+	assert.Equal(t, ErrInvalidToken.Code, rec.events[0].Reason)
+	assert.Empty(t, rec.events[0].UserID)
+	assert.Equal(t, "bob", rec.events[2].UserName)
+	assert.Equal(t, "bob", rec.events[3].UserName)
+}
+
+func TestEvents_TokenRejectedUser(t *testing.T) {
+	rec := &eventRecorder{}
+	config := makeTestConfig()
+	config.EventSink = rec
+	c := makectx(t, config, middlewareServer(config))
+
+	// signed token of deleted user
+	token := &Token{
+		UserID:    "deleted",
+		UserName:  "ghost",
+		ExpiredAt: Timestamp(now().Add(config.TokenExpiration)),
+	}
+	s, err := token.Encode(config)
+	assert.Nil(t, err)
+	c.expect.GET("/data").
+		WithHeader(authorizationHeader, "Bearer "+s).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	assert.Equal(t, []EventType{EventTokenRejected}, rec.types())
+	assert.Equal(t, ErrUserNotFound.Code, rec.events[0].Reason)
+	assert.Equal(t, "deleted", rec.events[0].UserID)
+	assert.Equal(t, "ghost", rec.events[0].UserName)
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	webSocketProtocolHeader = "Sec-WebSocket-Protocol"
+	defaultWebSocketPrefix  = "bearer."
+)
+
+// TokenExtractor extracts JWT token from request and reports authentication method of its source.
+// It returns empty token if request has no token in the source.
+type TokenExtractor func(config *Config, r *http.Request) (string, AuthMethod, *Error)
+
+// AuthorizationToken extracts token from 'Authorization: Bearer <token>' header.
+func AuthorizationToken(config *Config, r *http.Request) (string, AuthMethod, *Error) {
+	h := r.Header.Get(authorizationHeader)
+	if len(h) == 0 {
+		return "", AuthMethodBearer, nil
+	}
+	scheme, token, err := parseAuthorizationHeader(h)
+	if err != nil {
+		return "", AuthMethodBearer, err
+	}
+	if scheme != schemeBearer {
+		return "", AuthMethodBearer, ErrUnsupportedAuthScheme
+	}
+	return token, AuthMethodBearer, nil
+}
+
+// HeaderToken extracts raw token from custom header, e.g. X-Auth-Token.
+func HeaderToken(name string) TokenExtractor {
+	return func(config *Config, r *http.Request) (string, AuthMethod, *Error) {
+		return strings.TrimSpace(r.Header.Get(name)), AuthMethodAPIKey, nil
+	}
+}
+
+// CookieToken extracts token from cookie with given name, Config.TokenCookie if name is empty.
+func CookieToken(name string) TokenExtractor {
+	return func(config *Config, r *http.Request) (string, AuthMethod, *Error) {
+		cookieName := name
+		if len(cookieName) == 0 {
+			cookieName = config.TokenCookie
+		}
+		cookie, err := r.Cookie(cookieName)
+		if err != nil || cookie == nil {
+			return "", AuthMethodCookie, nil
+		}
+		return cookie.Value, AuthMethodCookie, nil
+	}
+}
+
+// QueryToken extracts token from query string parameter, Config.TokenKey if key is empty.
+// It respects Config.DisableQueryToken and Config.QueryTokenPaths.
+func QueryToken(key string) TokenExtractor {
+	return func(config *Config, r *http.Request) (string, AuthMethod, *Error) {
+		if !queryTokenAllowed(config, r) {
+			return "", AuthMethodQuery, nil
+		}
+		queryKey := key
+		if len(queryKey) == 0 {
+			queryKey = config.TokenKey
+		}
+		return r.URL.Query().Get(queryKey), AuthMethodQuery, nil
+	}
+}
+
+// FormToken extracts token from url-encoded form field.
+func FormToken(field string) TokenExtractor {
+	return func(config *Config, r *http.Request) (string, AuthMethod, *Error) {
+		mediaType := strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0])
+		if mediaType != contentForm {
+			return "", AuthMethodForm, nil
+		}
+		return r.PostFormValue(field), AuthMethodForm, nil
+	}
+}
+
+// WebSocketProtocolToken extracts token from Sec-WebSocket-Protocol subprotocol
+// that starts with given prefix, Config.WebSocketProtocolPrefix if prefix is empty.
+// Only subprotocols with Config.WebSocketProtocolPrefix are echoed by WebSocketProtocol.
+func WebSocketProtocolToken(prefix string) TokenExtractor {
+	return func(config *Config, r *http.Request) (string, AuthMethod, *Error) {
+		prefix := prefix
+		if len(prefix) == 0 {
+			prefix = config.WebSocketProtocolPrefix
+		}
+		for _, h := range r.Header[http.CanonicalHeaderKey(webSocketProtocolHeader)] {
+			for _, protocol := range strings.Split(h, ",") {
+				protocol = strings.TrimSpace(protocol)
+				if strings.HasPrefix(protocol, prefix) {
+					return strings.TrimPrefix(protocol, prefix), AuthMethodWebSocketProtocol, nil
+				}
+			}
+		}
+		return "", AuthMethodWebSocketProtocol, nil
+	}
+}
+
+func queryTokenAllowed(config *Config, r *http.Request) bool {
+	if config.DisableQueryToken {
+		return false
+	}
+	if len(config.QueryTokenPaths) == 0 {
+		return true
+	}
+	for _, prefix := range config.QueryTokenPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractToken returns first token found in configured sources and method of the source.
+func extractToken(config *Config, r *http.Request) (string, AuthMethod, *Error) {
+	for _, extract := range config.TokenExtractors {
+		token, method, err := extract(config, r)
+		if err != nil {
+			return "", "", err
+		}
+		if len(token) > 0 {
+			return token, method, nil
+		}
+	}
+	return "", "", nil
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryToken_Disabled(t *testing.T) {
+	config := makeTestConfig()
+	config.DisableQueryToken = true
+	c := makectx(t, config, middlewareServer(config))
+	token := c.makeToken("bob", "b0b")
+
+	c.expect.GET("/data").
+		WithQuery(defaultTokenKey, token).
+		Expect().
+		Status(http.StatusUnauthorized)
+}
+
+func TestQueryToken_AllowedPaths(t *testing.T) {
+	config := makeTestConfig()
+	config.QueryTokenPaths = []string{"/admin/"}
+	c := makectx(t, config, middlewareServer(config))
+	token := c.makeToken("admin", "admin")
+
+	c.expect.GET("/data").
+		WithQuery(defaultTokenKey, token).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	c.expect.GET("/admin/data").
+		WithQuery(defaultTokenKey, token).
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestCookieToken(t *testing.T) {
+	config := makeTestConfig()
+	c := makectx(t, config, middlewareServer(config))
+	token := c.makeToken("bob", "b0b")
+
+	c.expect.GET("/data").
+		WithCookie(defaultTokenCookie, token).
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestCustomTokenExtractors(t *testing.T) {
+	config := makeTestConfig()
+	config.TokenExtractors = []TokenExtractor{
+		HeaderToken("X-Auth-Token"),
+		WebSocketProtocolToken(""),
+		FormToken("access_token"),
+		func(config *Config, r *http.Request) (string, AuthMethod, *Error) {
+			return r.Header.Get("X-Custom"), AuthMethodBearer, nil
+		},
+	}
+	c := makectx(t, config, middlewareServer(config))
+	token := c.makeToken("bob", "b0b")
+
+	c.expect.GET("/data").
+		WithHeader("X-Auth-Token", token).
+		Expect().
+		Status(http.StatusOK)
+
+	c.expect.GET("/data").
+		WithHeader(webSocketProtocolHeader, fmt.Sprintf("chat, bearer.%s", token)).
+		Expect().
+		Status(http.StatusOK)
+
+	c.expect.POST("/form").
+		WithFormField("access_token", token).
+		Expect().
+		Status(http.StatusOK)
+
+	c.expect.GET("/data").
+		WithHeader("X-Custom", token).
+		Expect().
+		Status(http.StatusOK)
+
+	// sources not listed are ignored
+	c.expect.GET("/data").
+		WithHeader(authorizationHeader, fmt.Sprintf("%s %s", schemeBearer, token)).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	c.expect.GET("/data").
+		WithQuery(defaultTokenKey, token).
+		Expect().
+		Status(http.StatusUnauthorized)
+}
+
+func TestTokenExtractors_AuthMethod(t *testing.T) {
+	config := makeTestConfig()
+	config.TokenExtractors = []TokenExtractor{
+		AuthorizationToken,
+		HeaderToken("X-Auth-Token"),
+		CookieToken(""),
+		QueryToken(""),
+		FormToken("access_token"),
+		WebSocketProtocolToken(""),
+	}
+	c := &C{T: t, config: config}
+	token := c.makeToken("bob", "b0b")
+
+	var method AuthMethod
+	h := RequireUser(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = GetAuthInfo(r.Context()).Method
+	}))
+	for expected, setup := range map[AuthMethod]func(r *http.Request){
+		AuthMethodBearer:            func(r *http.Request) { r.Header.Set(authorizationHeader, "Bearer "+token) },
+		AuthMethodAPIKey:            func(r *http.Request) { r.Header.Set("X-Auth-Token", token) },
+		AuthMethodCookie:            func(r *http.Request) { r.AddCookie(&http.Cookie{Name: defaultTokenCookie, Value: token}) },
+		AuthMethodQuery:             func(r *http.Request) { r.URL.RawQuery = defaultTokenKey + "=" + token },
+		AuthMethodWebSocketProtocol: func(r *http.Request) { r.Header.Set(webSocketProtocolHeader, "bearer."+token) },
+		AuthMethodForm: func(r *http.Request) {
+			r.Method = http.MethodPost
+			r.Header.Set("Content-Type", contentForm)
+			r.Body = ioutil.NopCloser(strings.NewReader("access_token=" + token))
+		},
+	} {
+		r := httptest.NewRequest("GET", "/data", nil)
+		setup(r)
+		w := httptest.NewRecorder()
+		method = ""
+		h.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code, expected)
+		assert.Equal(t, expected, method)
+	}
+}
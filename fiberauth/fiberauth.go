@@ -0,0 +1,98 @@
+// Package fiberauth adapts auth middleware and handlers to fiber framework.
+package fiberauth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gocontrib/auth"
+	"github.com/gocontrib/auth/internal/adapter"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+// UserKey is fiber locals key of authenticated user.
+const UserKey = adapter.UserKey
+
+// RequireUser creates fiber middleware that authenticates requests with given configuration.
+// Request context values set by auth middleware, e.g. auth.AuthInfo, are put into user context,
+// see fiber.Ctx.UserContext.
+func RequireUser(config *auth.Config) fiber.Handler {
+	return wrap(auth.RequireUser(config))
+}
+
+// RequireAdmin creates fiber middleware that authenticates only admin users.
+func RequireAdmin(config *auth.Config) fiber.Handler {
+	return wrap(auth.RequireAdmin(config))
+}
+
+func wrap(middleware func(http.Handler) http.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var ctx context.Context
+		h := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx = r.Context()
+		}))
+		// net/http middleware writes error response or headers into fiber response
+		err := adaptor.HTTPHandler(h)(c)
+		if err != nil || ctx == nil {
+			return err
+		}
+		if user := auth.GetContextUser(ctx); user != nil {
+			c.Locals(UserKey, user)
+		}
+		c.SetUserContext(&valueContext{Context: c.UserContext(), values: ctx})
+		return c.Next()
+	}
+}
+
+// valueContext exposes values of net/http request context like auth.AuthInfo
+// on top of fiber user context, which keeps its own deadline and cancellation.
+type valueContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c *valueContext) Value(key interface{}) interface{} {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+// LoginHandler wraps auth.LoginHandler.
+func LoginHandler(config *auth.Config) fiber.Handler {
+	return adaptor.HTTPHandler(auth.LoginHandler(config))
+}
+
+// RegisterHandler wraps auth.RegisterHandler.
+func RegisterHandler(config *auth.Config) fiber.Handler {
+	return adaptor.HTTPHandler(auth.RegisterHandler(config))
+}
+
+// CheckTokenHandler wraps auth.CheckTokenHandler.
+func CheckTokenHandler(config *auth.Config) fiber.Handler {
+	return adaptor.HTTPHandler(auth.CheckTokenHandler(config))
+}
+
+// GetUser returns authenticated user of given fiber context.
+func GetUser(c *fiber.Ctx) auth.User {
+	if user, ok := c.Locals(UserKey).(auth.User); ok {
+		return user
+	}
+	return auth.GetContextUser(c.UserContext())
+}
+
+// Router adapts fiber routes to oauth.Router.
+type Router struct {
+	routes fiber.Router
+}
+
+// NewRouter creates oauth.Router registering routes in given fiber router.
+func NewRouter(routes fiber.Router) *Router {
+	return &Router{routes: routes}
+}
+
+// Get registers handler for chi-style pattern like /api/oauth/login/{provider}.
+func (r *Router) Get(pattern string, h http.HandlerFunc) {
+	r.routes.Get(adapter.ConvertPattern(pattern), adaptor.HTTPHandlerFunc(h))
+}
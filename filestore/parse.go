@@ -0,0 +1,146 @@
+package filestore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/gocontrib/auth/password"
+	"gopkg.in/yaml.v3"
+)
+
+// Format of users file.
+type Format int
+
+const (
+	// FormatAuto detects format by file extension, .yaml/.yml and .json files are users files, others are htpasswd.
+	FormatAuto Format = iota
+	// FormatHtpasswd is Apache htpasswd file with name:hash lines.
+	FormatHtpasswd
+	// FormatYAML is YAML users file.
+	FormatYAML
+	// FormatJSON is JSON users file.
+	FormatJSON
+)
+
+var errNoUsers = errors.New("file has no users")
+
+func detectFormat(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	}
+	return FormatHtpasswd
+}
+
+// usersFile is schema of YAML and JSON users files:
+//
+//	users:
+//	  - name: bob
+//	    email: bob@example.com
+//	    password: $2y$05$...
+//	    roles: [admin]
+//	    claims:
+//	      team: backend
+type usersFile struct {
+	Users []*fileUser `json:"users" yaml:"users"`
+}
+
+type fileUser struct {
+	// ID is name of the user if it is empty
+	ID       string                 `json:"id" yaml:"id"`
+	Name     string                 `json:"name" yaml:"name"`
+	Email    string                 `json:"email" yaml:"email"`
+	Password string                 `json:"password" yaml:"password"`
+	Roles    []string               `json:"roles" yaml:"roles"`
+	Claims   map[string]interface{} `json:"claims" yaml:"claims"`
+}
+
+func parse(data []byte, format Format, adminRole string) (*userSet, error) {
+	var users []*fileUser
+	var err error
+	switch format {
+	case FormatYAML:
+		users, err = parseYAML(data)
+	case FormatJSON:
+		users, err = parseJSON(data)
+	default:
+		users, err = parseHtpasswd(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// empty file is most likely truncated by non-atomic write in progress
+	if len(users) == 0 {
+		return nil, errNoUsers
+	}
+
+	set := newUserSet()
+	for i, u := range users {
+		if u == nil || len(u.Name) == 0 {
+			return nil, fmt.Errorf("user #%d has no name", i+1)
+		}
+		if len(u.Password) > 0 && !password.IsKnownFormat(u.Password) {
+			return nil, fmt.Errorf("user %s: %w", u.Name, password.ErrUnknownFormat)
+		}
+		if err := set.add(u, adminRole); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+func parseYAML(data []byte) ([]*fileUser, error) {
+	var f usersFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		if err == io.EOF {
+			return nil, errNoUsers
+		}
+		return nil, err
+	}
+	return f.Users, nil
+}
+
+func parseJSON(data []byte) ([]*fileUser, error) {
+	var f usersFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+	return f.Users, nil
+}
+
+// parseHtpasswd reads name:hash lines, blank lines and # comments are skipped.
+// Last line must be terminated, otherwise file is considered partially written.
+func parseHtpasswd(data []byte) ([]*fileUser, error) {
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		return nil, errors.New("last line is not terminated, file may be partially written")
+	}
+	var users []*fileUser
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i <= 0 || i == len(line)-1 {
+			return nil, fmt.Errorf("line %d: expected name:hash", n)
+		}
+		users = append(users, &fileUser{
+			Name:     line[:i],
+			Password: line[i+1:],
+		})
+	}
+	return users, scanner.Err()
+}
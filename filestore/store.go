@@ -0,0 +1,323 @@
+// Package filestore implements auth.UserStore over Apache htpasswd or YAML/JSON users file.
+// File is watched and reloaded on change, malformed or empty file never replaces previously loaded users.
+// Write the file to temporary one and rename it over, partially written file may still parse.
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocontrib/auth"
+	"github.com/gocontrib/auth/password"
+)
+
+const (
+	defaultAdminRole = "admin"
+	defaultInterval  = 2 * time.Second
+)
+
+var (
+	// ErrNotFound is returned when user does not exist.
+	ErrNotFound = errors.New("filestore: user not found")
+	// ErrInvalidCredentials is returned when username or password does not match.
+	ErrInvalidCredentials = errors.New("filestore: invalid credentials")
+)
+
+// Options of file store.
+type Options struct {
+	// Format of the file, detected by extension by default
+	Format Format
+	// Interval between checks of file changes, 2 seconds by default, negative value disables watching
+	Interval time.Duration
+	// AdminRole is role of admin users, 'admin' by default
+	AdminRole string
+	// OnReload is called after file is reloaded on change with nil or error of the reload.
+	// Previously loaded users are kept on error.
+	OnReload func(err error)
+}
+
+// Store is read-only user store backed by file.
+type Store struct {
+	path    string
+	options Options
+
+	mu    sync.RWMutex
+	users *userSet
+	err   error
+	info  os.FileInfo
+
+	done      chan struct{}
+	closeOnce sync.Once
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+// New loads users from given file and starts watching it.
+// It fails if file cannot be loaded initially.
+func New(path string, options Options) (*Store, error) {
+	if options.Format == FormatAuto {
+		options.Format = detectFormat(path)
+	}
+	if options.Interval == 0 {
+		options.Interval = defaultInterval
+	}
+	if len(options.AdminRole) == 0 {
+		options.AdminRole = defaultAdminRole
+	}
+	s := &Store{
+		path:    path,
+		options: options,
+		done:    make(chan struct{}),
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	if options.Interval > 0 {
+		go s.watch()
+	}
+	return s, nil
+}
+
+// Reload reads the file and atomically replaces loaded users.
+// On error previously loaded users are kept and error is also reported by Err.
+func (s *Store) Reload() error {
+	// stat before read, so change made during read is detected by next check
+	info, err := os.Stat(s.path)
+	var users *userSet
+	if err == nil {
+		users, err = s.load()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+	if users != nil {
+		s.users = users
+	}
+	if info != nil {
+		// do not retry until file is changed again
+		s.info = info
+	}
+	return err
+}
+
+func (s *Store) load() (*userSet, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	users, err := parse(data, s.options.Format, s.options.AdminRole)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: %s: %w", s.path, err)
+	}
+	return users, nil
+}
+
+// Err returns error of the last reload, nil if it was successful.
+func (s *Store) Err() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.err
+}
+
+// Len returns number of loaded users.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.users.byID)
+}
+
+func (s *Store) watch() {
+	ticker := time.NewTicker(s.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if !s.changed() {
+				continue
+			}
+			err := s.Reload()
+			if s.options.OnReload != nil {
+				s.options.OnReload(err)
+			}
+		}
+	}
+}
+
+// changed reports whether file was modified, replaced or removed since last reload.
+// Replaced file is detected by inode even if it has the same size and modification time.
+func (s *Store) changed() bool {
+	info, err := os.Stat(s.path)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err != nil {
+		// report missing file once
+		return s.err == nil
+	}
+	if s.info == nil {
+		return true
+	}
+	return !os.SameFile(info, s.info) || !info.ModTime().Equal(s.info.ModTime()) || info.Size() != s.info.Size()
+}
+
+func (s *Store) snapshot() *userSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.users
+}
+
+func (s *Store) ValidateCredentials(ctx context.Context, username, pwd string) (auth.User, error) {
+	e := s.snapshot().find(username)
+	if e == nil || len(e.hash) == 0 {
+		// spend the same time as for existing user to not reveal valid usernames
+		password.Verify(pwd, s.dummy())
+		return nil, ErrInvalidCredentials
+	}
+	ok, err := password.Verify(pwd, e.hash)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return e.user, nil
+}
+
+// dummy returns hash verified for unknown users.
+func (s *Store) dummy() string {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = password.Default().Hash("dummy password")
+	})
+	return s.dummyHash
+}
+
+func (s *Store) FindUserByID(ctx context.Context, userID string) (auth.User, error) {
+	e, ok := s.snapshot().byID[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return e.user, nil
+}
+
+// Close stops watching the file.
+func (s *Store) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// User loaded from file, it must not be modified.
+type User struct {
+	ID     string
+	Name   string
+	Email  string
+	Roles  []string
+	Claims map[string]interface{}
+	admin  bool
+}
+
+func (u *User) GetID() string {
+	return u.ID
+}
+
+func (u *User) GetName() string {
+	return u.Name
+}
+
+func (u *User) GetEmail() string {
+	return u.Email
+}
+
+func (u *User) IsAdmin() bool {
+	return u.admin
+}
+
+func (u *User) GetClaims() map[string]interface{} {
+	claims := make(map[string]interface{}, len(u.Claims)+1)
+	for k, v := range u.Claims {
+		claims[k] = v
+	}
+	if len(u.Roles) > 0 {
+		claims["roles"] = u.Roles
+	}
+	return claims
+}
+
+type entry struct {
+	user *User
+	hash string
+}
+
+// userSet is immutable set of users loaded from file.
+type userSet struct {
+	byID    map[string]*entry
+	byName  map[string]*entry
+	byEmail map[string]*entry
+}
+
+func newUserSet() *userSet {
+	return &userSet{
+		byID:    make(map[string]*entry),
+		byName:  make(map[string]*entry),
+		byEmail: make(map[string]*entry),
+	}
+}
+
+func (set *userSet) add(u *fileUser, adminRole string) error {
+	e := &entry{
+		user: &User{
+			ID:     u.ID,
+			Name:   u.Name,
+			Email:  strings.ToLower(u.Email),
+			Roles:  u.Roles,
+			Claims: u.Claims,
+		},
+		hash: u.Password,
+	}
+	if len(e.user.ID) == 0 {
+		e.user.ID = u.Name
+	}
+	// logins with '@' are looked up by email only, so such name is email of the user
+	if len(e.user.Email) == 0 && strings.Contains(u.Name, "@") {
+		e.user.Email = strings.ToLower(u.Name)
+	}
+	for _, role := range u.Roles {
+		if role == adminRole {
+			e.user.admin = true
+		}
+	}
+
+	if _, ok := set.byID[e.user.ID]; ok {
+		return fmt.Errorf("duplicate user id %s", e.user.ID)
+	}
+	if _, ok := set.byName[e.user.Name]; ok {
+		return fmt.Errorf("duplicate user name %s", e.user.Name)
+	}
+	if len(e.user.Email) > 0 {
+		if _, ok := set.byEmail[e.user.Email]; ok {
+			return fmt.Errorf("duplicate email %s", e.user.Email)
+		}
+		set.byEmail[e.user.Email] = e
+	}
+	set.byID[e.user.ID] = e
+	set.byName[e.user.Name] = e
+	return nil
+}
+
+// find looks user up by email if username contains '@', by name otherwise.
+func (set *userSet) find(username string) *entry {
+	if strings.Contains(username, "@") {
+		return set.byEmail[strings.ToLower(username)]
+	}
+	return set.byName[username]
+}
+
+var _ auth.UserStore = (*Store)(nil)
@@ -0,0 +1,272 @@
+package filestore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocontrib/auth/password"
+	"github.com/stretchr/testify/assert"
+)
+
+// hashes of "password"
+const (
+	apr1Hash = "$apr1$saltsalt$yAAkm4libquA.ZWLHbSBq/"
+	shaHash  = "{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g="
+)
+
+func writeFile(t *testing.T, dir, name, data string) string {
+	path := filepath.Join(dir, name)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(data), 0600))
+	return path
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "filestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func bcryptHash(t *testing.T, pwd string) string {
+	hash, err := (&password.Bcrypt{Cost: 4}).Hash(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestHtpasswd(t *testing.T) {
+	ctx := context.Background()
+	path := writeFile(t, tempDir(t), ".htpasswd", "# users\n"+
+		"bob:"+bcryptHash(t, "b0b")+"\n\n"+
+		"joe:"+apr1Hash+"\n"+
+		"rob:"+shaHash+"\n")
+
+	s, err := New(path, Options{Interval: -1})
+	assert.Nil(t, err)
+	defer s.Close()
+	assert.Equal(t, 3, s.Len())
+
+	for name, pwd := range map[string]string{"bob": "b0b", "joe": "password", "rob": "password"} {
+		user, err := s.ValidateCredentials(ctx, name, pwd)
+		assert.Nil(t, err, name)
+		assert.Equal(t, name, user.GetID())
+		assert.False(t, user.IsAdmin())
+
+		_, err = s.ValidateCredentials(ctx, name, "wrong")
+		assert.Equal(t, ErrInvalidCredentials, err)
+	}
+
+	_, err = s.ValidateCredentials(ctx, "nobody", "password")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	user, err := s.FindUserByID(ctx, "joe")
+	assert.Nil(t, err)
+	assert.Equal(t, "joe", user.GetName())
+	_, err = s.FindUserByID(ctx, "nobody")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestYAML(t *testing.T) {
+	ctx := context.Background()
+	path := writeFile(t, tempDir(t), "users.yaml", `
+users:
+  - name: bob
+    email: Bob@Example.com
+    password: "`+apr1Hash+`"
+    roles: [admin]
+    claims:
+      team: backend
+  - id: "42"
+    name: joe
+    password: "`+shaHash+`"
+`)
+
+	s, err := New(path, Options{Interval: -1})
+	assert.Nil(t, err)
+	defer s.Close()
+
+	user, err := s.ValidateCredentials(ctx, "bob@example.com", "password")
+	assert.Nil(t, err)
+	assert.Equal(t, "bob", user.GetID())
+	assert.Equal(t, "bob@example.com", user.GetEmail())
+	assert.True(t, user.IsAdmin())
+	assert.Equal(t, map[string]interface{}{"team": "backend", "roles": []string{"admin"}}, user.GetClaims())
+
+	user, err = s.FindUserByID(ctx, "42")
+	assert.Nil(t, err)
+	assert.Equal(t, "joe", user.GetName())
+	assert.False(t, user.IsAdmin())
+	assert.Equal(t, map[string]interface{}{}, user.GetClaims())
+}
+
+func TestLoginRouting(t *testing.T) {
+	ctx := context.Background()
+	path := writeFile(t, tempDir(t), "users.yaml", `
+users:
+  - name: bob
+    email: bob@example.com
+    password: "`+shaHash+`"
+  - name: Joe@Corp
+    password: "`+shaHash+`"
+`)
+
+	s, err := New(path, Options{Interval: -1})
+	assert.Nil(t, err)
+	defer s.Close()
+
+	// names with '@' are emails
+	user, err := s.ValidateCredentials(ctx, "joe@corp", "password")
+	assert.Nil(t, err)
+	assert.Equal(t, "Joe@Corp", user.GetID())
+	assert.Equal(t, "joe@corp", user.GetEmail())
+
+	_, err = s.ValidateCredentials(ctx, "bob", "password")
+	assert.Nil(t, err)
+	_, err = s.ValidateCredentials(ctx, "nobody", "password")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	// unknown users verify dummy hash
+	assert.NotEmpty(t, s.dummyHash)
+}
+
+func TestJSON(t *testing.T) {
+	ctx := context.Background()
+	path := writeFile(t, tempDir(t), "users.json", `{"users": [
+		{"name": "bob", "password": "`+shaHash+`", "roles": ["staff"]}
+	]}`)
+
+	s, err := New(path, Options{Interval: -1, AdminRole: "staff"})
+	assert.Nil(t, err)
+	defer s.Close()
+
+	user, err := s.ValidateCredentials(ctx, "bob", "password")
+	assert.Nil(t, err)
+	assert.True(t, user.IsAdmin())
+}
+
+func TestParseErrors(t *testing.T) {
+	dir := tempDir(t)
+	for name, data := range map[string]string{
+		"missing-hash":    "bob:\n",
+		"no-separator":    "bob\n",
+		"plaintext":       "bob:password\n",
+		"duplicate":       "bob:" + shaHash + "\nbob:" + apr1Hash + "\n",
+		"unknown.yaml":    "users:\n  - name: bob\n    passwd: x\n",
+		"noname.yaml":     "users:\n  - email: bob@example.com\n",
+		"dup-email.yaml":  "users:\n  - name: a\n    email: x@y\n  - name: b\n    email: X@Y\n",
+		"malformed.json":  `{"users": [`,
+		"duplicate.json":  `{"users": [{"id": "1", "name": "a"}, {"id": "1", "name": "b"}]}`,
+		"unknown.json":    `{"users": [], "groups": []}`,
+		"bad-format.json": `{"users": [{"name": "a", "password": "secret"}]}`,
+		"empty":           "",
+		"comments":        "# no users\n",
+		"unterminated":    "bob:" + shaHash + "\njoe:" + shaHash[:10],
+		"empty.yaml":      "",
+		"no-users.yaml":   "users: []\n",
+		"empty.json":      "",
+		"no-users.json":   `{"users": []}`,
+	} {
+		_, err := New(writeFile(t, dir, name, data), Options{Interval: -1})
+		assert.NotNil(t, err, name)
+	}
+
+	_, err := New(filepath.Join(dir, "missing"), Options{Interval: -1})
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReload_KeepsUsersOnError(t *testing.T) {
+	ctx := context.Background()
+	dir := tempDir(t)
+	path := writeFile(t, dir, ".htpasswd", "bob:"+shaHash+"\n")
+
+	s, err := New(path, Options{Interval: -1})
+	assert.Nil(t, err)
+	defer s.Close()
+
+	writeFile(t, dir, ".htpasswd", "bob:"+shaHash+"\njoe\n")
+	assert.NotNil(t, s.Reload())
+	assert.NotNil(t, s.Err())
+	_, err = s.ValidateCredentials(ctx, "bob", "password")
+	assert.Nil(t, err)
+
+	writeFile(t, dir, ".htpasswd", "joe:"+shaHash+"\n")
+	assert.Nil(t, s.Reload())
+	assert.Nil(t, s.Err())
+	_, err = s.FindUserByID(ctx, "bob")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = s.ValidateCredentials(ctx, "joe", "password")
+	assert.Nil(t, err)
+
+	// truncated by non-atomic save in progress
+	writeFile(t, dir, ".htpasswd", "")
+	assert.NotNil(t, s.Reload())
+	_, err = s.ValidateCredentials(ctx, "joe", "password")
+	assert.Nil(t, err)
+}
+
+func TestChanged_ReplacedFile(t *testing.T) {
+	dir := tempDir(t)
+	path := writeFile(t, dir, ".htpasswd", "bob:"+shaHash+"\n")
+
+	s, err := New(path, Options{Interval: -1})
+	assert.Nil(t, err)
+	defer s.Close()
+	assert.False(t, s.changed())
+
+	// same size and modification time, but different file
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	tmp := writeFile(t, dir, "users.tmp", "joe:"+shaHash+"\n")
+	assert.Nil(t, os.Chtimes(tmp, info.ModTime(), info.ModTime()))
+	assert.Nil(t, os.Rename(tmp, path))
+	assert.True(t, s.changed())
+}
+
+func TestWatch(t *testing.T) {
+	ctx := context.Background()
+	dir := tempDir(t)
+	path := writeFile(t, dir, "users.yaml", "users:\n  - name: bob\n")
+
+	var mu sync.Mutex
+	var reloads []error
+	s, err := New(path, Options{
+		Interval: 10 * time.Millisecond,
+		OnReload: func(err error) {
+			mu.Lock()
+			reloads = append(reloads, err)
+			mu.Unlock()
+		},
+	})
+	assert.Nil(t, err)
+	defer s.Close()
+
+	// replace file atomically like editors and config management do
+	tmp := writeFile(t, dir, "users.tmp", "users:\n  - name: bob\n  - name: joe\n")
+	assert.Nil(t, os.Rename(tmp, path))
+	assert.Eventually(t, func() bool {
+		_, err := s.FindUserByID(ctx, "joe")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	tmp = writeFile(t, dir, "users.tmp", "users: [")
+	assert.Nil(t, os.Rename(tmp, path))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reloads) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.NotNil(t, s.Err())
+	assert.Equal(t, 2, s.Len())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Nil(t, reloads[0])
+	assert.NotNil(t, reloads[1])
+}
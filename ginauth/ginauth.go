@@ -0,0 +1,84 @@
+// Package ginauth adapts auth middleware and handlers to gin framework.
+package ginauth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gocontrib/auth"
+	"github.com/gocontrib/auth/internal/adapter"
+)
+
+// UserKey is gin context key of authenticated user.
+const UserKey = adapter.UserKey
+
+// RequireUser creates gin middleware that authenticates requests with given configuration.
+func RequireUser(config *auth.Config) gin.HandlerFunc {
+	return wrap(auth.RequireUser(config))
+}
+
+// RequireAdmin creates gin middleware that authenticates only admin users.
+func RequireAdmin(config *auth.Config) gin.HandlerFunc {
+	return wrap(auth.RequireAdmin(config))
+}
+
+func wrap(middleware func(http.Handler) http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authenticated := false
+		h := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authenticated = true
+			c.Request = r
+			if user := auth.GetContextUser(r.Context()); user != nil {
+				c.Set(UserKey, user)
+			}
+			c.Next()
+		}))
+		h.ServeHTTP(c.Writer, c.Request)
+		if !authenticated {
+			c.Abort()
+		}
+	}
+}
+
+// LoginHandler wraps auth.LoginHandler.
+func LoginHandler(config *auth.Config) gin.HandlerFunc {
+	return gin.WrapH(auth.LoginHandler(config))
+}
+
+// RegisterHandler wraps auth.RegisterHandler.
+func RegisterHandler(config *auth.Config) gin.HandlerFunc {
+	return gin.WrapH(auth.RegisterHandler(config))
+}
+
+// CheckTokenHandler wraps auth.CheckTokenHandler.
+func CheckTokenHandler(config *auth.Config) gin.HandlerFunc {
+	return gin.WrapH(auth.CheckTokenHandler(config))
+}
+
+// GetUser returns authenticated user of given gin context.
+func GetUser(c *gin.Context) auth.User {
+	if v, ok := c.Get(UserKey); ok {
+		if user, ok := v.(auth.User); ok {
+			return user
+		}
+	}
+	if c.Request == nil {
+		return nil
+	}
+	return auth.GetContextUser(c.Request.Context())
+}
+
+// Router adapts gin routes to oauth.Router.
+type Router struct {
+	routes gin.IRoutes
+}
+
+// NewRouter creates oauth.Router registering routes in given gin router.
+func NewRouter(routes gin.IRoutes) *Router {
+	return &Router{routes: routes}
+}
+
+// Get registers handler for chi-style pattern like /api/oauth/login/{provider}.
+func (r *Router) Get(pattern string, h http.HandlerFunc) {
+	r.routes.GET(adapter.ConvertPattern(pattern), gin.WrapF(h))
+}
@@ -0,0 +1,57 @@
+package grpcauth
+
+import (
+	"context"
+	"encoding/base64"
+
+	"google.golang.org/grpc/credentials"
+)
+
+type perRPCCredentials struct {
+	authorization func(ctx context.Context) (string, error)
+	requireTLS    bool
+}
+
+// TokenCredentials attaches given JWT token to every call as bearer authorization.
+func TokenCredentials(token string, requireTLS bool) credentials.PerRPCCredentials {
+	return TokenSourceCredentials(func(ctx context.Context) (string, error) {
+		return token, nil
+	}, requireTLS)
+}
+
+// TokenSourceCredentials attaches token returned by source to every call, e.g. to refresh expired tokens.
+func TokenSourceCredentials(source func(ctx context.Context) (string, error), requireTLS bool) credentials.PerRPCCredentials {
+	return &perRPCCredentials{
+		authorization: func(ctx context.Context) (string, error) {
+			token, err := source(ctx)
+			if err != nil {
+				return "", err
+			}
+			return "Bearer " + token, nil
+		},
+		requireTLS: requireTLS,
+	}
+}
+
+// BasicCredentials attaches given username and password to every call as basic authorization.
+func BasicCredentials(username, password string, requireTLS bool) credentials.PerRPCCredentials {
+	value := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	return &perRPCCredentials{
+		authorization: func(ctx context.Context) (string, error) {
+			return value, nil
+		},
+		requireTLS: requireTLS,
+	}
+}
+
+func (c *perRPCCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	value, err := c.authorization(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": value}, nil
+}
+
+func (c *perRPCCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
@@ -0,0 +1,186 @@
+package grpcauth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gocontrib/auth"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testStore map[string]*auth.UserInfo
+
+func (s testStore) ValidateCredentials(ctx context.Context, username, password string) (auth.User, error) {
+	u, ok := s[username]
+	if !ok || u.Pwd != password {
+		return nil, errors.New("invalid credentials")
+	}
+	return u, nil
+}
+
+func (s testStore) FindUserByID(ctx context.Context, userID string) (auth.User, error) {
+	for _, u := range s {
+		if u.ID == userID {
+			return u, nil
+		}
+	}
+	return nil, errors.New("user not found")
+}
+
+func (s testStore) Close() {}
+
+func makeTestConfig() *auth.Config {
+	return (&auth.Config{
+		UserStore: testStore{
+			"bob":   {ID: "1", Name: "bob", Pwd: "b0b"},
+			"admin": {ID: "2", Name: "admin", Pwd: "admin", Admin: true},
+		},
+	}).SetDefaults()
+}
+
+// healthServer records authenticated user of last call.
+type healthServer struct {
+	*health.Server
+	user auth.User
+}
+
+func (s *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.user = auth.GetContextUser(ctx)
+	return s.Server.Check(ctx, req)
+}
+
+func (s *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	s.user = auth.GetContextUser(stream.Context())
+	return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+}
+
+func startServer(t *testing.T, opts ...grpc.ServerOption) (*healthServer, func(creds credentials.PerRPCCredentials) (healthpb.HealthClient, func())) {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(opts...)
+	hs := &healthServer{Server: health.NewServer()}
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	dial := func(creds credentials.PerRPCCredentials) (healthpb.HealthClient, func()) {
+		dialOpts := []grpc.DialOption{
+			grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		}
+		if creds != nil {
+			dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(creds))
+		}
+		conn, err := grpc.Dial("bufnet", dialOpts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return healthpb.NewHealthClient(conn), func() { conn.Close() }
+	}
+	return hs, dial
+}
+
+func makeToken(t *testing.T, config *auth.Config, userID string) string {
+	token := &auth.Token{
+		UserID:    userID,
+		ExpiredAt: auth.Timestamp(time.Now().Add(time.Hour)),
+	}
+	s, err := token.Encode(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	config := makeTestConfig()
+	hs, dial := startServer(t, grpc.UnaryInterceptor(UnaryServerInterceptor(config)))
+	ctx := context.Background()
+
+	client, closeConn := dial(nil)
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	closeConn()
+
+	client, closeConn = dial(TokenCredentials("invalid", false))
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, auth.ErrInvalidToken.Message, status.Convert(err).Message())
+	closeConn()
+
+	client, closeConn = dial(TokenCredentials(makeToken(t, config, "1"), false))
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", hs.user.GetName())
+	closeConn()
+
+	client, closeConn = dial(BasicCredentials("admin", "admin", false))
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", hs.user.GetName())
+	closeConn()
+
+	client, closeConn = dial(BasicCredentials("bob", "wrong", false))
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	closeConn()
+}
+
+func TestAdminUnaryServerInterceptor(t *testing.T) {
+	config := makeTestConfig()
+	hs, dial := startServer(t, grpc.UnaryInterceptor(AdminUnaryServerInterceptor(config)))
+	ctx := context.Background()
+
+	client, closeConn := dial(BasicCredentials("bob", "b0b", false))
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	closeConn()
+
+	client, closeConn = dial(TokenCredentials(makeToken(t, config, "2"), false))
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", hs.user.GetName())
+	closeConn()
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	config := makeTestConfig()
+	hs, dial := startServer(t, grpc.StreamInterceptor(StreamServerInterceptor(config)))
+	ctx := context.Background()
+
+	client, closeConn := dial(nil)
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	closeConn()
+
+	client, closeConn = dial(TokenCredentials(makeToken(t, config, "1"), false))
+	stream, err = client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	res, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+	assert.Equal(t, "bob", hs.user.GetName())
+	closeConn()
+}
+
+func TestCode(t *testing.T) {
+	assert.Equal(t, codes.Unauthenticated, Code(auth.ErrInvalidToken))
+	assert.Equal(t, codes.PermissionDenied, Code(auth.ErrNotAdmin))
+	assert.Equal(t, codes.ResourceExhausted, Code(auth.ErrTooManyRequests))
+	assert.Equal(t, codes.Internal, Code(auth.ErrBadState))
+}
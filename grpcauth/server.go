@@ -0,0 +1,110 @@
+package grpcauth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gocontrib/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor authenticates unary calls with given configuration.
+func UnaryServerInterceptor(config *auth.Config) grpc.UnaryServerInterceptor {
+	return unaryInterceptor(config.SetDefaults(), false)
+}
+
+// AdminUnaryServerInterceptor authenticates unary calls of admin users only.
+func AdminUnaryServerInterceptor(config *auth.Config) grpc.UnaryServerInterceptor {
+	return unaryInterceptor(config.SetDefaults(), true)
+}
+
+// StreamServerInterceptor authenticates streaming calls with given configuration.
+func StreamServerInterceptor(config *auth.Config) grpc.StreamServerInterceptor {
+	return streamInterceptor(config.SetDefaults(), false)
+}
+
+// AdminStreamServerInterceptor authenticates streaming calls of admin users only.
+func AdminStreamServerInterceptor(config *auth.Config) grpc.StreamServerInterceptor {
+	return streamInterceptor(config.SetDefaults(), true)
+}
+
+func unaryInterceptor(config *auth.Config, requireAdmin bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(config, ctx, info.FullMethod, requireAdmin)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamInterceptor(config *auth.Config, requireAdmin bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(config, ss.Context(), info.FullMethod, requireAdmin)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(config *auth.Config, ctx context.Context, method string, requireAdmin bool) (context.Context, error) {
+	r := makeRequest(ctx, method)
+	result, err := auth.Authenticate(config, r, requireAdmin)
+	if err != nil {
+		config.LogError(r, err)
+		return nil, Status(err)
+	}
+	return result, nil
+}
+
+// makeRequest adapts incoming call to HTTP request, so client IP, events and metrics work as for HTTP.
+func makeRequest(ctx context.Context, method string) *http.Request {
+	r, _ := http.NewRequest(http.MethodPost, method, nil)
+	r = r.WithContext(ctx)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for k, values := range md {
+			for _, v := range values {
+				r.Header.Add(k, v)
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r
+}
+
+// Status converts auth error to gRPC status error.
+func Status(err *auth.Error) error {
+	return status.Error(Code(err), err.Message)
+}
+
+// Code returns gRPC status code of given auth error.
+func Code(err *auth.Error) codes.Code {
+	switch err.Status {
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
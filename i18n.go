@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Message keys of built-in pages, errors are keyed by their codes.
+const (
+	MessageOAuthSuccess = "oauth.success"
+	MessageOAuthError   = "oauth.error"
+)
+
+const defaultLanguage = "en"
+
+// Catalog keeps localized messages keyed by language and message key.
+type Catalog struct {
+	sync.RWMutex
+	fallback string
+	messages map[string]map[string]string
+}
+
+// NewCatalog creates empty catalog with given fallback language.
+func NewCatalog(fallback string) *Catalog {
+	return &Catalog{
+		fallback: normalizeLanguage(fallback),
+		messages: make(map[string]map[string]string),
+	}
+}
+
+// DefaultCatalog contains built-in English messages, applications may add translations.
+var DefaultCatalog = NewCatalog(defaultLanguage)
+
+func init() {
+	DefaultCatalog.Set(defaultLanguage, map[string]string{
+		MessageOAuthSuccess: "Hey, buddy!",
+		MessageOAuthError:   "Oops, your OAuth failed! Please try again later",
+	})
+}
+
+// Set adds or overrides messages of given language.
+func (c *Catalog) Set(lang string, messages map[string]string) {
+	lang = normalizeLanguage(lang)
+	c.Lock()
+	defer c.Unlock()
+	m, ok := c.messages[lang]
+	if !ok {
+		m = make(map[string]string)
+		c.messages[lang] = m
+	}
+	for k, v := range messages {
+		m[k] = v
+	}
+}
+
+// Languages returns sorted list of languages having messages.
+func (c *Catalog) Languages() []string {
+	c.RLock()
+	defer c.RUnlock()
+	var result []string
+	for lang := range c.messages {
+		result = append(result, lang)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Negotiate returns best supported language for given Accept-Language header.
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	c.RLock()
+	defer c.RUnlock()
+
+	best := c.fallback
+	bestQ := 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := normalizeLanguage(fields[0])
+		if len(tag) == 0 || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		if lang, ok := c.match(tag); ok {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// match finds supported language for tag, falling back from region to base language.
+func (c *Catalog) match(tag string) (string, bool) {
+	if _, ok := c.messages[tag]; ok {
+		return tag, true
+	}
+	if i := strings.Index(tag, "-"); i > 0 {
+		if _, ok := c.messages[tag[:i]]; ok {
+			return tag[:i], true
+		}
+	}
+	return "", false
+}
+
+// Message returns message for given language falling back to fallback language and default.
+func (c *Catalog) Message(lang, key, def string) string {
+	msg, _ := c.lookup(lang, key, def)
+	return msg
+}
+
+// lookup returns message like Message and its language, empty one if default is returned.
+func (c *Catalog) lookup(lang, key, def string) (string, string) {
+	c.RLock()
+	defer c.RUnlock()
+	lang = normalizeLanguage(lang)
+	if msg, ok := c.messages[lang][key]; ok {
+		return msg, lang
+	}
+	if msg, ok := c.messages[c.fallback][key]; ok {
+		return msg, c.fallback
+	}
+	return def, ""
+}
+
+// Translate returns message in language negotiated by request Accept-Language header.
+func (c *Catalog) Translate(r *http.Request, key, def string) string {
+	if r == nil {
+		return c.Message(c.fallback, key, def)
+	}
+	return c.Message(c.Negotiate(r.Header.Get("Accept-Language")), key, def)
+}
+
+// localizeError returns copy of the error with message in language of the request
+// and language of the message, empty if message is not localized.
+func localizeError(config *Config, r *http.Request, err *Error) (*Error, string) {
+	if config.Messages == nil || r == nil {
+		return err, ""
+	}
+	lang := config.Messages.Negotiate(r.Header.Get("Accept-Language"))
+	result := *err
+	result.Message, lang = config.Messages.lookup(lang, err.Code, err.Message)
+	return &result, lang
+}
+
+func normalizeLanguage(tag string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(tag), "_", "-", -1))
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTestCatalog() *Catalog {
+	catalog := NewCatalog("en")
+	catalog.Set("en", map[string]string{MessageOAuthSuccess: "Welcome"})
+	catalog.Set("de", map[string]string{ErrInvalidToken.Code: "Ungültiges Token"})
+	catalog.Set("pt-BR", map[string]string{ErrInvalidToken.Code: "Token inválido"})
+	return catalog
+}
+
+func TestCatalog_Negotiate(t *testing.T) {
+	catalog := makeTestCatalog()
+	assert.Equal(t, "en", catalog.Negotiate(""))
+	assert.Equal(t, "de", catalog.Negotiate("de-CH, en;q=0.8"))
+	assert.Equal(t, "en", catalog.Negotiate("de;q=0.5, en;q=0.8"))
+	assert.Equal(t, "pt-br", catalog.Negotiate("pt_BR"))
+	assert.Equal(t, "en", catalog.Negotiate("fr, *;q=0.5"))
+	assert.Equal(t, []string{"de", "en", "pt-br"}, catalog.Languages())
+}
+
+func TestCatalog_Message(t *testing.T) {
+	catalog := makeTestCatalog()
+	assert.Equal(t, "Ungültiges Token", catalog.Message("de", ErrInvalidToken.Code, ErrInvalidToken.Message))
+	assert.Equal(t, "Welcome", catalog.Message("de", MessageOAuthSuccess, ""))
+	assert.Equal(t, ErrNotAdmin.Message, catalog.Message("de", ErrNotAdmin.Code, ErrNotAdmin.Message))
+
+	catalog.Set("en", map[string]string{MessageOAuthSuccess: "Hello"})
+	assert.Equal(t, "Hello", catalog.Message("en", MessageOAuthSuccess, ""))
+}
+
+func TestError_Localized(t *testing.T) {
+	config := makeTestConfig()
+	config.Messages = makeTestCatalog()
+	c := makectx(t, config, middlewareServer(config))
+
+	res := c.expect.GET("/data").
+		WithHeader(authorizationHeader, "Bearer invalid").
+		WithHeader("Accept-Language", "de-DE,de;q=0.9").
+		Expect().
+		Status(http.StatusUnauthorized)
+	res.Header("Content-Language").Equal("de")
+	assert.Equal(t, []string{"Accept-Language", "Accept"}, res.Raw().Header.Values("Vary"))
+	res.JSON().Object().Value("error_message").String().Equal("Ungültiges Token")
+
+	res = c.expect.GET("/data").
+		WithHeader(authorizationHeader, "Bearer invalid").
+		WithHeader("Accept-Language", "fr").
+		Expect().
+		Status(http.StatusUnauthorized)
+	res.JSON().Object().Value("error_message").String().Equal(ErrInvalidToken.Message)
+	// built-in message is not localized by catalog
+	res.Header("Content-Language").Empty()
+
+	// message of fallback language
+	config.Messages.Set("en", map[string]string{ErrInvalidToken.Code: "Bad token"})
+	res = c.expect.GET("/data").
+		WithHeader(authorizationHeader, "Bearer invalid").
+		WithHeader("Accept-Language", "fr").
+		Expect().
+		Status(http.StatusUnauthorized)
+	res.JSON().Object().Value("error_message").String().Equal("Bad token")
+	res.Header("Content-Language").Equal("en")
+}
+
+func TestErrorHandler_Localized(t *testing.T) {
+	config := makeTestConfig()
+	config.Messages = makeTestCatalog()
+	config.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err *Error) {
+		w.WriteHeader(err.Status)
+		w.Write([]byte(err.Message))
+	}
+	c := makectx(t, config, middlewareServer(config))
+
+	res := c.expect.GET("/data").
+		WithHeader(authorizationHeader, "Bearer invalid").
+		WithHeader("Accept-Language", "de").
+		Expect().
+		Status(http.StatusUnauthorized)
+	res.Header("Content-Language").Equal("de")
+	res.Body().Equal("Ungültiges Token")
+}
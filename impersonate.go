@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+const defaultImpersonationTTL = 15 * time.Minute
+
+// ImpersonationPolicy configures admin impersonation of users.
+type ImpersonationPolicy struct {
+	// TTL is lifetime of impersonation token, 15 minutes by default
+	TTL time.Duration
+	// CanImpersonate reports whether actor may impersonate target user.
+	// By default admins may impersonate non-admin users only.
+	CanImpersonate func(actor, target User) bool
+	// RestrictedPaths are path prefixes blocked while impersonating
+	RestrictedPaths []string
+	// Revocations keeps impersonation tokens ended by EndImpersonationHandler,
+	// in-memory store by default. Use shared store if several instances validate tokens.
+	Revocations RevocationStore
+}
+
+// RevocationStore keeps IDs of revoked tokens until they expire.
+type RevocationStore interface {
+	Revoke(ctx context.Context, id string, expiredAt time.Time) error
+	IsRevoked(ctx context.Context, id string) (bool, error)
+}
+
+func (p *ImpersonationPolicy) ttl() time.Duration {
+	if p != nil && p.TTL > 0 {
+		return p.TTL
+	}
+	return defaultImpersonationTTL
+}
+
+func (p *ImpersonationPolicy) canImpersonate(actor, target User) bool {
+	if actor.GetID() == target.GetID() {
+		return false
+	}
+	if p != nil && p.CanImpersonate != nil {
+		return p.CanImpersonate(actor, target)
+	}
+	return actor.IsAdmin() && !target.IsAdmin()
+}
+
+func (p *ImpersonationPolicy) isRestricted(r *http.Request) bool {
+	if p == nil {
+		return false
+	}
+	for _, prefix := range p.RestrictedPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetImpersonator returns admin impersonating user of given request, nil if request is not impersonated.
+func GetImpersonator(r *http.Request) User {
+	if info := GetAuthInfo(r.Context()); info != nil {
+		return info.Actor
+	}
+	return nil
+}
+
+// ImpersonateHandler issues short-lived token of user given in {"user_id": "..."} payload
+// with 'act' claim naming the caller. Only admins can impersonate unless
+// ImpersonationPolicy.CanImpersonate is set.
+func ImpersonateHandler(config *Config) http.Handler {
+	config = config.SetDefaults()
+	policy := config.Impersonation
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := GetAuthInfo(r.Context())
+		if info.Actor != nil {
+			SendRequestError(config, w, r, ErrImpersonationNested)
+			return
+		}
+		actor := info.User
+
+		in := &struct {
+			UserID string `json:"user_id"`
+		}{}
+		err := json.NewDecoder(r.Body).Decode(in)
+		if err != nil || len(in.UserID) == 0 {
+			SendRequestError(config, w, r, ErrMalformedContent)
+			return
+		}
+
+		target, err := callFindUserByID(config, r.Context(), in.UserID)
+		if err != nil {
+			SendRequestError(config, w, r, ErrUserNotFound.WithCause(err))
+			return
+		}
+		if !policy.canImpersonate(actor, target) {
+			SendRequestError(config, w, r, ErrImpersonationForbidden)
+			return
+		}
+
+		token := MakeToken(r, config, target)
+		token.ID = base64.RawURLEncoding.EncodeToString(securecookie.GenerateRandomKey(16))
+		token.ExpiredAt = Timestamp(time.Time(token.IssuedAt).Add(policy.ttl()))
+		token.Actor = &Actor{
+			UserID:   actor.GetID(),
+			UserName: actor.GetName(),
+		}
+		tokenString, err2 := token.Encode(config)
+		if err2 != nil {
+			SendRequestError(config, w, r, err2)
+			return
+		}
+		emitImpersonationEvent(config, r, EventImpersonationStart, target, actor)
+
+		SendJSON(w, &LoginResponse{
+			Token:     tokenString,
+			UserID:    token.UserID,
+			UserName:  token.UserName,
+			ExpiredAt: token.ExpiredAt,
+		})
+	})
+
+	if policy != nil && policy.CanImpersonate != nil {
+		return RequireUser(config)(h)
+	}
+	return RequireAdmin(config)(h)
+}
+
+// EndImpersonationHandler revokes impersonation token of the request and records end of impersonation.
+func EndImpersonationHandler(config *Config) http.Handler {
+	config = config.SetDefaults()
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := GetAuthInfo(r.Context())
+		if info.Actor != nil && info.Token != nil {
+			err := config.Impersonation.Revocations.Revoke(r.Context(), info.Token.ID, time.Time(info.Token.ExpiredAt))
+			if err != nil {
+				SendRequestError(config, w, r, ErrBadState.WithCause(err))
+				return
+			}
+			emitImpersonationEvent(config, r, EventImpersonationEnd, info.User, info.Actor)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return RequireUser(config)(h)
+}
+
+// checkImpersonation validates actor of impersonation token for given request.
+func checkImpersonation(config *Config, r *http.Request, token *Token, user User) (User, *Error) {
+	if err := checkRevoked(config, r.Context(), token); err != nil {
+		return nil, err
+	}
+	actor, err := callFindUserByID(config, r.Context(), token.Actor.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound.WithCause(err)
+	}
+	// actor could lose privileges after token was issued
+	if !config.Impersonation.canImpersonate(actor, user) {
+		return nil, ErrImpersonationForbidden
+	}
+	if config.Impersonation.isRestricted(r) {
+		return nil, ErrImpersonationRestricted
+	}
+	return actor, nil
+}
+
+// checkRevoked rejects impersonation token ended by EndImpersonationHandler.
+// Impersonation tokens without ID cannot be revoked, so they are rejected too.
+func checkRevoked(config *Config, ctx context.Context, token *Token) *Error {
+	if len(token.ID) == 0 {
+		return ErrInvalidToken
+	}
+	if config.Impersonation == nil || config.Impersonation.Revocations == nil {
+		return nil
+	}
+	revoked, err := config.Impersonation.Revocations.IsRevoked(ctx, token.ID)
+	if err != nil {
+		return ErrBadState.WithCause(err)
+	}
+	if revoked {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+func emitImpersonationEvent(config *Config, r *http.Request, eventType EventType, user, actor User) {
+	if config.EventSink == nil {
+		return
+	}
+	EmitEvent(config, r, Event{
+		Type:      eventType,
+		UserID:    user.GetID(),
+		UserName:  user.GetName(),
+		ActorID:   actor.GetID(),
+		ActorName: actor.GetName(),
+	})
+}
+
+// NewMemoryRevocationStore creates in-memory RevocationStore for single instance deployments.
+func NewMemoryRevocationStore() RevocationStore {
+	return &memoryRevocationStore{
+		revoked: make(map[string]time.Time),
+	}
+}
+
+type memoryRevocationStore struct {
+	sync.Mutex
+	revoked map[string]time.Time
+}
+
+func (s *memoryRevocationStore) Revoke(ctx context.Context, id string, expiredAt time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+	t := now()
+	for k, v := range s.revoked {
+		if !t.Before(v) {
+			delete(s.revoked, k)
+		}
+	}
+	s.revoked[id] = expiredAt
+	return nil
+}
+
+func (s *memoryRevocationStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	_, ok := s.revoked[id]
+	return ok, nil
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func impersonationServer(config *Config) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/impersonate", ImpersonateHandler(config))
+	mux.Handle("/impersonate/end", EndImpersonationHandler(config))
+	mux.Handle("/token", CheckTokenHandler(config))
+	whoami := RequireUser(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := "-"
+		if impersonator := GetImpersonator(r); impersonator != nil {
+			actor = impersonator.GetName()
+		}
+		fmt.Fprint(w, GetRequestUser(r).GetName(), " ", actor)
+	}))
+	mux.Handle("/whoami", whoami)
+	mux.Handle("/billing/pay", whoami)
+	return mux
+}
+
+func impersonationRequest(config *Config, target string) *http.Request {
+	userID := config.UserStore.(testUserStore)[target].ID
+	return httptest.NewRequest("POST", "/impersonate", strings.NewReader(fmt.Sprintf(`{"user_id":%q}`, userID)))
+}
+
+func impersonate(t *testing.T, h http.Handler, config *Config, username, password, target string) (*httptest.ResponseRecorder, string) {
+	r := impersonationRequest(config, target)
+	r.SetBasicAuth(username, password)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	res := &LoginResponse{}
+	json.Unmarshal(w.Body.Bytes(), res)
+	return w, res.Token
+}
+
+func bearerRequest(method, target, token string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set(authorizationHeader, "Bearer "+token)
+	return r
+}
+
+func TestImpersonate(t *testing.T) {
+	rec := &eventRecorder{}
+	config := makeTestConfig()
+	config.EventSink = rec
+	config.Impersonation = &ImpersonationPolicy{RestrictedPaths: []string{"/billing/"}}
+	h := impersonationServer(config)
+
+	w, token := impersonate(t, h, config, "admin", "admin", "bob")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, token)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, bearerRequest("GET", "/whoami", token))
+	assert.Equal(t, "bob admin", w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, bearerRequest("POST", "/billing/pay", token))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), ErrImpersonationRestricted.Code)
+
+	// impersonation token cannot be refreshed into regular one
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, bearerRequest("GET", "/token", token))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, bearerRequest("POST", "/impersonate/end", token))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// ended impersonation token is revoked
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, bearerRequest("GET", "/whoami", token))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), ErrSessionRevoked.Code)
+
+	assert.Contains(t, rec.types(), EventImpersonationStart)
+	assert.Contains(t, rec.types(), EventImpersonationEnd)
+	for _, e := range rec.events {
+		if e.Type == EventImpersonationStart || e.Type == EventImpersonationEnd {
+			assert.Equal(t, "bob", e.UserName)
+			assert.Equal(t, "admin", e.ActorName)
+		}
+	}
+}
+
+func TestImpersonate_Forbidden(t *testing.T) {
+	config := makeTestConfig()
+	h := impersonationServer(config)
+
+	w, _ := impersonate(t, h, config, "bob", "b0b", "joe")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), ErrNotAdmin.Code)
+
+	// admins cannot be impersonated by default
+	w, _ = impersonate(t, h, config, "admin", "admin", "admin")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), ErrImpersonationForbidden.Code)
+}
+
+func TestImpersonate_NotNested(t *testing.T) {
+	config := makeTestConfig()
+	config.Impersonation = &ImpersonationPolicy{
+		CanImpersonate: func(actor, target User) bool { return true },
+	}
+	h := impersonationServer(config)
+
+	_, token := impersonate(t, h, config, "bob", "b0b", "joe")
+	assert.NotEmpty(t, token)
+
+	r := impersonationRequest(config, "admin")
+	r.Header.Set(authorizationHeader, "Bearer "+token)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), ErrImpersonationNested.Code)
+}
+
+func TestImpersonate_RequiresTokenID(t *testing.T) {
+	config := makeTestConfig()
+	h := impersonationServer(config)
+	bob := config.UserStore.(testUserStore)["bob"]
+	admin := config.UserStore.(testUserStore)["admin"]
+
+	token := &Token{
+		UserID:    bob.ID,
+		UserName:  bob.Name,
+		ExpiredAt: Timestamp(now().Add(config.TokenExpiration)),
+		Actor:     &Actor{UserID: admin.ID, UserName: admin.Name},
+	}
+	s, err := token.Encode(config)
+	assert.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, bearerRequest("GET", "/whoami", s))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	token.ID = "id"
+	s, _ = token.Encode(config)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, bearerRequest("GET", "/whoami", s))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestToken_ActClaim(t *testing.T) {
+	config := makeTestConfig()
+	token := &Token{
+		UserID:    "1",
+		ExpiredAt: Timestamp(now().Add(config.TokenExpiration)),
+		Claims:    map[string]interface{}{"act": map[string]interface{}{"sub": "spoofed"}},
+	}
+	s, err := token.Encode(config)
+	assert.Nil(t, err)
+	parsed, err := parseToken(config, s, "", false)
+	assert.Nil(t, err)
+	assert.Nil(t, parsed.Actor)
+
+	token.Actor = &Actor{UserID: "2", UserName: "admin"}
+	s, _ = token.Encode(config)
+	parsed, err = parseToken(config, s, "", false)
+	assert.Nil(t, err)
+	assert.Equal(t, token.Actor, parsed.Actor)
+}
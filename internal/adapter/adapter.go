@@ -0,0 +1,18 @@
+// Package adapter holds helpers shared by framework adapters.
+package adapter
+
+import "strings"
+
+// UserKey is framework context key of authenticated user.
+const UserKey = "auth.user"
+
+// ConvertPattern replaces {param} segments of chi-style pattern with :param ones.
+func ConvertPattern(pattern string) string {
+	parts := strings.Split(pattern, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			parts[i] = ":" + p[1:len(p)-1]
+		}
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,12 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertPattern(t *testing.T) {
+	assert.Equal(t, "/api/oauth/login/:provider", ConvertPattern("/api/oauth/login/{provider}"))
+	assert.Equal(t, "/api/oauth/success", ConvertPattern("/api/oauth/success"))
+}
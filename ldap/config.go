@@ -1,6 +1,7 @@
 package ldap
 
 import (
+	"github.com/gocontrib/auth/metrics"
 	ldapclient "github.com/gocontrib/go-ldap-client"
 )
 
@@ -18,5 +19,6 @@ type Config struct {
 	DisplayNameAttr    string
 	EmailAttr          string
 	PoolCapacity       int
+	Metrics            *metrics.Registry // reports pool gauges if set
 	GetMoreUserInfo    func(client *ldapclient.LDAPClient, attrs map[string]string) (map[string]string, error)
 }
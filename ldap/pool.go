@@ -3,7 +3,9 @@ package ldap
 import (
 	"errors"
 	"sync"
+	"sync/atomic"
 
+	"github.com/gocontrib/auth/metrics"
 	ldapclient "github.com/gocontrib/go-ldap-client"
 	"gopkg.in/ldap.v2"
 )
@@ -16,23 +18,69 @@ type Pool interface {
 	Close()
 	Get() (*ldapclient.LDAPClient, error)
 	Put(*ldapclient.LDAPClient) error
+	Stats() PoolStats
+}
+
+// PoolStats describes connection pool usage.
+type PoolStats struct {
+	Idle      int64
+	InUse     int64
+	Dials     int64
+	Discarded int64 // dead connections detected by liveness check
+}
+
+// poolCounters are updated atomically.
+type poolCounters struct {
+	inUse     int64
+	dials     int64
+	discarded int64
+}
+
+func (c *poolCounters) stats(idle int64) PoolStats {
+	return PoolStats{
+		Idle:      idle,
+		InUse:     atomic.LoadInt64(&c.inUse),
+		Dials:     atomic.LoadInt64(&c.dials),
+		Discarded: atomic.LoadInt64(&c.discarded),
+	}
 }
 
 func NewPool(config Config) Pool {
+	var pool Pool
 	capacity := config.PoolCapacity
 	if capacity <= 0 {
-		return &unlimitedPool{config}
+		pool = &unlimitedPool{config: config}
+	} else {
+		pool = &chanPool{
+			config: config,
+			conns:  make(chan *ldapclient.LDAPClient, capacity),
+		}
 	}
-
-	return &chanPool{
-		config: config,
-		conns:  make(chan *ldapclient.LDAPClient, capacity),
+	if config.Metrics != nil {
+		registerPoolMetrics(config.Metrics, pool)
 	}
+	return pool
+}
+
+func registerPoolMetrics(r *metrics.Registry, pool Pool) {
+	r.GaugeFunc("ldap_pool_idle_connections", "Number of idle LDAP connections.", func() float64 {
+		return float64(pool.Stats().Idle)
+	})
+	r.GaugeFunc("ldap_pool_in_use_connections", "Number of LDAP connections in use.", func() float64 {
+		return float64(pool.Stats().InUse)
+	})
+	r.CounterFunc("ldap_pool_dials_total", "Number of LDAP connections created.", func() float64 {
+		return float64(pool.Stats().Dials)
+	})
+	r.CounterFunc("ldap_pool_dead_connections_total", "Number of dead LDAP connections discarded.", func() float64 {
+		return float64(pool.Stats().Discarded)
+	})
 }
 
 // based on https://github.com/fatih/pool
 type chanPool struct {
 	sync.Mutex
+	poolCounters
 	config Config
 	conns  chan *ldapclient.LDAPClient
 }
@@ -69,9 +117,11 @@ func (p *chanPool) Get() (*ldapclient.LDAPClient, error) {
 			return nil, errPoolClosed
 		}
 		if isAlive(conn) {
+			atomic.AddInt64(&p.inUse, 1)
 			return conn, nil
 		}
 		// dead connection
+		atomic.AddInt64(&p.discarded, 1)
 		conn.Close()
 		return p.NewConn()
 	default:
@@ -79,6 +129,13 @@ func (p *chanPool) Get() (*ldapclient.LDAPClient, error) {
 	}
 }
 
+func (p *chanPool) Stats() PoolStats {
+	p.Lock()
+	idle := int64(len(p.conns))
+	p.Unlock()
+	return p.stats(idle)
+}
+
 func isAlive(conn *ldapclient.LDAPClient) bool {
 	if conn == nil || conn.Conn == nil {
 		return false
@@ -94,6 +151,8 @@ func isAlive(conn *ldapclient.LDAPClient) bool {
 
 func (p *chanPool) NewConn() (*ldapclient.LDAPClient, error) {
 	conn := makeClient(p.config)
+	atomic.AddInt64(&p.dials, 1)
+	atomic.AddInt64(&p.inUse, 1)
 	return conn, nil
 }
 
@@ -102,6 +161,8 @@ func (p *chanPool) Put(conn *ldapclient.LDAPClient) error {
 		return errors.New("connection is nil. rejecting")
 	}
 
+	atomic.AddInt64(&p.inUse, -1)
+
 	p.Lock()
 	defer p.Unlock()
 
@@ -122,20 +183,28 @@ func (p *chanPool) Put(conn *ldapclient.LDAPClient) error {
 }
 
 type unlimitedPool struct {
+	poolCounters
 	config Config
 }
 
 func (p *unlimitedPool) Close() {}
 
 func (p *unlimitedPool) Get() (*ldapclient.LDAPClient, error) {
+	atomic.AddInt64(&p.dials, 1)
+	atomic.AddInt64(&p.inUse, 1)
 	return makeClient(p.config), nil
 }
 
 func (p *unlimitedPool) Put(conn *ldapclient.LDAPClient) error {
+	atomic.AddInt64(&p.inUse, -1)
 	conn.Close()
 	return nil
 }
 
+func (p *unlimitedPool) Stats() PoolStats {
+	return p.stats(0)
+}
+
 func makeClient(config Config) *ldapclient.LDAPClient {
 	return &ldapclient.LDAPClient{
 		Base:               config.Base,
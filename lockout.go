@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Attempts describes failed login attempts of account.
+type Attempts struct {
+	// Failures is number of consecutive failures since last lock or success
+	Failures int
+	// Lockouts is number of consecutive locks, used for exponential backoff
+	Lockouts    int
+	LockedUntil time.Time
+	// LastFailure is time of the last failure
+	LastFailure time.Time
+}
+
+// AttemptStore keeps failed login attempts per username.
+// Implementations may share state between instances.
+type AttemptStore interface {
+	Get(ctx context.Context, username string) (Attempts, error)
+	// RegisterFailure atomically applies policy.Fail to attempts of given user and returns the result,
+	// so concurrent failures are never lost.
+	RegisterFailure(ctx context.Context, username string, policy *LockoutPolicy) (Attempts, error)
+	Reset(ctx context.Context, username string) error
+}
+
+// LockoutPolicy locks account for growing interval after MaxFailures consecutive failures.
+// Failures and locks are forgotten when account had no failures for as long as its next lock would last.
+type LockoutPolicy struct {
+	Store       AttemptStore
+	MaxFailures int
+	// Duration of first lock, doubled on each subsequent lock
+	Duration time.Duration
+	// MaxDuration caps lock duration
+	MaxDuration time.Duration
+}
+
+func (p *LockoutPolicy) lockDuration(lockouts int) time.Duration {
+	d := p.Duration
+	for i := 1; i < lockouts; i++ {
+		d *= 2
+		if p.MaxDuration > 0 && d >= p.MaxDuration {
+			return p.MaxDuration
+		}
+	}
+	if p.MaxDuration > 0 && d > p.MaxDuration {
+		return p.MaxDuration
+	}
+	return d
+}
+
+// Fail returns attempts after one more failure at time t, account is locked when MaxFailures is reached.
+func (p *LockoutPolicy) Fail(attempts Attempts, t time.Time) Attempts {
+	if p.Expired(attempts, t) {
+		attempts = Attempts{}
+	}
+	attempts.Failures++
+	attempts.LastFailure = t
+	if attempts.Failures >= p.MaxFailures {
+		attempts.Failures = 0
+		attempts.Lockouts++
+		attempts.LockedUntil = t.Add(p.lockDuration(attempts.Lockouts))
+	}
+	return attempts
+}
+
+// Expired reports whether attempts are forgotten at time t, so store may drop them.
+func (p *LockoutPolicy) Expired(attempts Attempts, t time.Time) bool {
+	return !t.Before(attempts.LockedUntil) && t.Sub(attempts.LastFailure) >= p.lockDuration(attempts.Lockouts+1)
+}
+
+func lockoutEnabled(config *Config) bool {
+	return config.Lockout != nil && config.Lockout.Store != nil && config.Lockout.MaxFailures > 0
+}
+
+func lockoutKey(username string) string {
+	return strings.ToLower(username)
+}
+
+// checkLockout returns error if account is locked.
+// It never touches user store to avoid revealing whether user exists.
+func checkLockout(config *Config, r *http.Request, username string) *Error {
+	if !lockoutEnabled(config) {
+		return nil
+	}
+	attempts, err := config.Lockout.Store.Get(r.Context(), lockoutKey(username))
+	if err != nil {
+		config.Log(r, LogStoreFailure, LevelError, "attempt store failed", Fields{"error": err})
+		return nil
+	}
+	if t := now(); t.Before(attempts.LockedUntil) {
+		return ErrAccountLocked.WithRetryAfter(attempts.LockedUntil.Sub(t))
+	}
+	return nil
+}
+
+// registerLoginAttempt resets failures on success or counts failure and locks account.
+func registerLoginAttempt(config *Config, r *http.Request, username string, success bool) {
+	if !lockoutEnabled(config) {
+		return
+	}
+	ctx := r.Context()
+	policy := config.Lockout
+	key := lockoutKey(username)
+
+	if success {
+		if err := policy.Store.Reset(ctx, key); err != nil {
+			config.Log(r, LogStoreFailure, LevelError, "attempt store failed", Fields{"error": err})
+		}
+		return
+	}
+
+	if _, err := policy.Store.RegisterFailure(ctx, key, policy); err != nil {
+		config.Log(r, LogStoreFailure, LevelError, "attempt store failed", Fields{"error": err})
+	}
+}
+
+// UnlockAccount resets failed attempts of given user.
+func UnlockAccount(ctx context.Context, config *Config, username string) error {
+	if !lockoutEnabled(config) {
+		return nil
+	}
+	return config.Lockout.Store.Reset(ctx, lockoutKey(username))
+}
+
+// UnlockHandler allows admins to unlock account, expects {"username": "..."} payload.
+func UnlockHandler(config *Config) http.Handler {
+	config = config.SetDefaults()
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		in := &struct {
+			UserName string `json:"username"`
+		}{}
+		err := json.NewDecoder(r.Body).Decode(in)
+		if err != nil || len(in.UserName) == 0 {
+			SendRequestError(config, w, r, ErrMalformedContent)
+			return
+		}
+		err = UnlockAccount(r.Context(), config, in.UserName)
+		if err != nil {
+			SendRequestError(config, w, r, ErrBadState.WithCause(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return RequireAdmin(config)(h)
+}
+
+// NewMemoryAttemptStore creates in-memory AttemptStore for single instance deployments.
+func NewMemoryAttemptStore() AttemptStore {
+	return &memoryAttemptStore{
+		attempts: make(map[string]Attempts),
+	}
+}
+
+type memoryAttemptStore struct {
+	sync.Mutex
+	attempts  map[string]Attempts
+	policy    *LockoutPolicy
+	lastSweep time.Time
+}
+
+func (s *memoryAttemptStore) Get(ctx context.Context, username string) (Attempts, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.attempts[username], nil
+}
+
+func (s *memoryAttemptStore) RegisterFailure(ctx context.Context, username string, policy *LockoutPolicy) (Attempts, error) {
+	s.Lock()
+	defer s.Unlock()
+	t := now()
+	s.policy = policy
+	s.sweep(t)
+	attempts := policy.Fail(s.attempts[username], t)
+	s.attempts[username] = attempts
+	return attempts, nil
+}
+
+func (s *memoryAttemptStore) Reset(ctx context.Context, username string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.attempts, username)
+	return nil
+}
+
+// sweep drops expired attempts once a minute to bound memory usage.
+func (s *memoryAttemptStore) sweep(t time.Time) {
+	if t.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = t
+	for key, attempts := range s.attempts {
+		if s.policy.Expired(attempts, t) {
+			delete(s.attempts, key)
+		}
+	}
+}
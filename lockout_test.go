@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockoutPolicy_Backoff(t *testing.T) {
+	p := &LockoutPolicy{Duration: time.Minute, MaxDuration: 5 * time.Minute}
+	assert.Equal(t, time.Minute, p.lockDuration(1))
+	assert.Equal(t, 2*time.Minute, p.lockDuration(2))
+	assert.Equal(t, 4*time.Minute, p.lockDuration(3))
+	assert.Equal(t, 5*time.Minute, p.lockDuration(4))
+}
+
+func TestLockoutPolicy_Expired(t *testing.T) {
+	p := &LockoutPolicy{MaxFailures: 3, Duration: time.Minute}
+	t0 := time.Now()
+
+	a := p.Fail(p.Fail(Attempts{}, t0), t0)
+	assert.Equal(t, 2, a.Failures)
+	assert.False(t, p.Expired(a, t0.Add(59*time.Second)))
+	// failures are forgotten after quiet period
+	assert.Equal(t, 1, p.Fail(a, t0.Add(time.Minute)).Failures)
+
+	a = p.Fail(a, t0)
+	assert.Equal(t, 1, a.Lockouts)
+	// next lock lasts 2 minutes, so lock history is kept as long
+	assert.False(t, p.Expired(a, t0.Add(90*time.Second)))
+	assert.True(t, p.Expired(a, t0.Add(2*time.Minute)))
+}
+
+func TestMemoryAttemptStore_Sweep(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAttemptStore().(*memoryAttemptStore)
+	policy := &LockoutPolicy{MaxFailures: 2, Duration: time.Minute}
+	t0 := time.Now()
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return t0 }
+
+	for i := 0; i < 100; i++ {
+		store.RegisterFailure(ctx, fmt.Sprintf("user-%d", i), policy)
+	}
+	store.RegisterFailure(ctx, "bob", policy)
+	store.RegisterFailure(ctx, "bob", policy)
+	assert.Len(t, store.attempts, 101)
+
+	now = func() time.Time { return t0.Add(90 * time.Second) }
+	store.RegisterFailure(ctx, "joe", policy)
+	// lock history of bob is kept as long as next lock would last
+	assert.Len(t, store.attempts, 2)
+	attempts, _ := store.Get(ctx, "bob")
+	assert.Equal(t, 1, attempts.Lockouts)
+}
+
+func TestMemoryAttemptStore_ConcurrentFailures(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAttemptStore()
+	policy := &LockoutPolicy{MaxFailures: 1000, Duration: time.Minute}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.RegisterFailure(ctx, "bob", policy)
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	attempts, _ := store.Get(ctx, "bob")
+	assert.Equal(t, 50, attempts.Failures)
+}
+
+func TestLoginHandler_Lockout(t *testing.T) {
+	config := makeTestConfig()
+	config.Lockout = &LockoutPolicy{
+		Store:       NewMemoryAttemptStore(),
+		MaxFailures: 2,
+		Duration:    time.Minute,
+	}
+	handler := LoginHandler(config)
+	c := makectx(t, config, httptest.NewServer(handler))
+
+	for i := 0; i < 2; i++ {
+		c.expect.POST("/").WithJSON(&Credentials{UserName: "bob", Password: "1"}).
+			Expect().
+			Status(http.StatusUnauthorized).
+			JSON().Object().Value("error_code").String().Equal(ErrBadCredentials.Code)
+	}
+
+	c.expect.POST("/").WithJSON(&Credentials{UserName: "bob", Password: "b0b"}).
+		Expect().
+		Status(http.StatusUnauthorized).
+		JSON().Object().Value("error_code").String().Equal(ErrAccountLocked.Code)
+
+	// unknown users are locked the same way
+	for i := 0; i < 2; i++ {
+		c.expect.POST("/").WithJSON(&Credentials{UserName: "nobody", Password: "1"}).
+			Expect().
+			Status(http.StatusUnauthorized)
+	}
+	c.expect.POST("/").WithJSON(&Credentials{UserName: "nobody", Password: "1"}).
+		Expect().
+		Status(http.StatusUnauthorized).
+		JSON().Object().Value("error_code").String().Equal(ErrAccountLocked.Code)
+
+	err := UnlockAccount(context.Background(), config, "bob")
+	assert.Nil(t, err)
+
+	c.expect.POST("/").WithJSON(&Credentials{UserName: "bob", Password: "b0b"}).
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestUnlockHandler_RequiresAdmin(t *testing.T) {
+	config := makeTestConfig()
+	config.Lockout = &LockoutPolicy{
+		Store:       NewMemoryAttemptStore(),
+		MaxFailures: 1,
+		Duration:    time.Minute,
+	}
+	c := makectx(t, config, httptest.NewServer(UnlockHandler(config)))
+
+	c.expect.POST("/").WithBasicAuth("bob", "b0b").
+		WithJSON(map[string]string{"username": "rob"}).
+		Expect().
+		Status(http.StatusForbidden)
+
+	c.expect.POST("/").WithBasicAuth("admin", "admin").
+		WithJSON(map[string]string{"username": "bob"}).
+		Expect().
+		Status(http.StatusNoContent)
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Level of log message.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	// LevelOff disables logging of event
+	LevelOff
+)
+
+// Fields are structured log attributes.
+type Fields map[string]interface{}
+
+// Logger is minimal structured logger, see LogrusLogger and SlogLogger adapters.
+type Logger interface {
+	Log(ctx context.Context, level Level, msg string, fields Fields)
+}
+
+// Log events besides error codes and EventType values.
+const (
+	LogAuthError        = "auth_error"
+	LogStoreFailure     = "store_failure"
+	LogInvalidConfig    = "invalid_config"
+	LogOAuthProvider    = "oauth_provider"
+	redactedPlaceholder = "[REDACTED]"
+)
+
+// Log sends redacted message to Config.Logger with request ID attached.
+// Level can be overridden per event in Config.LogLevels, request may be nil.
+func (c *Config) Log(r *http.Request, event string, level Level, msg string, fields Fields) {
+	if c.Logger == nil {
+		return
+	}
+	if l, ok := c.LogLevels[event]; ok {
+		level = l
+	}
+	if level >= LevelOff {
+		return
+	}
+
+	result := redactFields(fields, c.LogMaskKey)
+	if result == nil {
+		result = Fields{}
+	}
+	result["event"] = event
+
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+		if id := c.RequestID(r); len(id) > 0 {
+			result["request_id"] = id
+		}
+	}
+
+	c.Logger.Log(ctx, level, Redact(msg), result)
+}
+
+// LogError logs auth error using its code as event.
+func (c *Config) LogError(r *http.Request, err *Error) {
+	level := LevelWarn
+	if err.Status >= http.StatusInternalServerError {
+		level = LevelError
+	}
+	if _, ok := c.LogLevels[err.Code]; !ok {
+		if l, ok := c.LogLevels[LogAuthError]; ok {
+			level = l
+		}
+	}
+	fields := Fields{
+		"code":   err.Code,
+		"status": err.Status,
+	}
+	if err.Cause != nil {
+		if credentialErrors[err.Code] {
+			fields["cause"] = redactedPlaceholder
+		} else {
+			fields["cause"] = err.Cause
+		}
+	}
+	c.Log(r, err.Code, level, "auth error: "+err.Message, fields)
+}
+
+var (
+	sensitiveKeys = []string{"password", "passwd", "pwd", "secret", "token", "authorization", "cookie", "credential"}
+	// personalKeys are masked with HMAC, so records of the same user can still be correlated.
+	// Usernames of failed logins often contain mistyped passwords.
+	personalKeys = []string{"user_name", "username", "email"}
+	// credentialErrors have causes from user stores that often quote usernames, e.g. LDAP errors.
+	credentialErrors = map[string]bool{ErrBadCredentials.Code: true, ErrUserNotFound.Code: true}
+
+	jwtPattern        = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*`)
+	authHeaderPattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`)
+	secretPattern     = regexp.MustCompile(`(?i)("?(?:password|passwd|pwd|secret|access_token|refresh_token|token)"?\s*[:=]\s*)("[^"]*"|[^&\s,;]+)`)
+)
+
+// Redact scrubs tokens, passwords and authorization credentials from given string.
+func Redact(s string) string {
+	s = jwtPattern.ReplaceAllString(s, redactedPlaceholder)
+	s = authHeaderPattern.ReplaceAllString(s, "$1 "+redactedPlaceholder)
+	s = secretPattern.ReplaceAllString(s, "${1}"+redactedPlaceholder)
+	return s
+}
+
+// logMaskKeyInfo binds key derived from SecretKey to masked log values.
+const logMaskKeyInfo = "gocontrib/auth log mask"
+
+// RedactFields returns copy of fields with sensitive keys and values scrubbed,
+// user names and emails are replaced with placeholder. Config.Log masks them with LogMaskKey instead.
+func RedactFields(fields Fields) Fields {
+	return redactFields(fields, nil)
+}
+
+func redactFields(fields Fields, maskKey []byte) Fields {
+	if fields == nil {
+		return nil
+	}
+	result := make(Fields, len(fields))
+	for k, v := range fields {
+		if isSensitiveKey(k) {
+			result[k] = redactedPlaceholder
+			continue
+		}
+		if isPersonalKey(k) {
+			result[k] = maskPersonal(maskKey, fmt.Sprint(v))
+			continue
+		}
+		switch t := v.(type) {
+		case string:
+			result[k] = Redact(t)
+		case error:
+			result[k] = Redact(t.Error())
+		case fmt.Stringer:
+			result[k] = Redact(t.String())
+		default:
+			result[k] = v
+		}
+	}
+	return result
+}
+
+func isPersonalKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range personalKeys {
+		if key == s {
+			return true
+		}
+	}
+	return false
+}
+
+// maskPersonal returns short keyed hash of given value, or placeholder without key.
+// Plain hashes of usernames and emails are easily reversed by dictionary.
+func maskPersonal(key []byte, value string) string {
+	if len(value) == 0 {
+		return ""
+	}
+	if len(key) == 0 {
+		return redactedPlaceholder
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// LogrusLogger adapts logrus logger.
+func LogrusLogger(l logrus.FieldLogger) Logger {
+	return &logrusLogger{l}
+}
+
+type logrusLogger struct {
+	logger logrus.FieldLogger
+}
+
+func (l *logrusLogger) Log(ctx context.Context, level Level, msg string, fields Fields) {
+	entry := l.logger.WithFields(logrus.Fields(fields))
+	switch level {
+	case LevelDebug:
+		entry.Debug(msg)
+	case LevelInfo:
+		entry.Info(msg)
+	case LevelWarn:
+		entry.Warn(msg)
+	default:
+		entry.Error(msg)
+	}
+}
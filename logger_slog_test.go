@@ -0,0 +1,20 @@
+//go:build go1.21
+// +build go1.21
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := SlogLogger(slog.New(slog.NewTextHandler(buf, nil)))
+	logger.Log(context.Background(), LevelWarn, "test", Fields{"code": "X"})
+	assert.Contains(t, buf.String(), "level=WARN msg=test code=X")
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"mime"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/schema"
 )
@@ -17,10 +18,11 @@ type Credentials struct {
 }
 
 type LoginResponse struct {
-	Token     string    `json:"token"`
+	Token     string    `json:"token,omitempty"`
 	UserID    string    `json:"user_id"`
 	UserName  string    `json:"user_name"`
 	ExpiredAt Timestamp `json:"expired_at"`
+	CSRFToken string    `json:"csrf_token,omitempty"`
 }
 
 func LoginHandler(config *Config) http.Handler {
@@ -33,20 +35,68 @@ func LoginHandlerFunc(config *Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		cred, err1 := decodeCredentials(w, r)
 		if err1 != nil {
-			SendError(w, err1)
+			SendRequestError(config, w, r, err1)
 			return
 		}
 
-		user, err2 := config.UserStore.ValidateCredentials(r.Context(), cred.UserName, cred.Password)
+		user, err2 := validateCredentials(config, r, cred.UserName, cred.Password)
 		if err2 != nil {
-			SendError(w, ErrBadCredentials.WithCause(err2))
+			SendRequestError(config, w, r, err2)
 			return
 		}
+		emitUserEvent(config, r, EventLoginSuccess, user)
+		// hashes are upgraded on interactive logins only, not on every API request with basic auth
+		upgradePassword(config, r, user, cred.Password)
 
 		WriteLoginResponse(w, r, config, user)
 	}
 }
 
+// validateCredentials checks rate limits and account lockout before touching user store.
+func validateCredentials(config *Config, r *http.Request, username, password string) (User, *Error) {
+	user, err := checkCredentials(config, r, username, password)
+	recordLoginAttempt(config, err)
+	if err != nil {
+		emitFailureEvent(config, r, EventLoginFailure, username, err)
+		return nil, err
+	}
+	return user, nil
+}
+
+func checkCredentials(config *Config, r *http.Request, username, password string) (User, *Error) {
+	tokens, rateErr := checkRateLimit(config, r, username)
+	if rateErr != nil {
+		return nil, rateErr
+	}
+	if err := checkLockout(config, r, username); err != nil {
+		return nil, err
+	}
+
+	user, err := callValidateCredentials(config, r.Context(), username, password)
+	registerLoginAttempt(config, r, username, err == nil)
+	if err != nil {
+		return nil, ErrBadCredentials.WithCause(err)
+	}
+	// only failed attempts are limited
+	refundRateLimit(config, r, tokens)
+
+	return user, nil
+}
+
+// upgradePassword lets store migrate password hash, failure does not fail the login.
+func upgradePassword(config *Config, r *http.Request, user User, password string) {
+	upgrader, ok := config.UserStore.(PasswordUpgrader)
+	if !ok {
+		return
+	}
+	start := time.Now()
+	err := upgrader.UpgradePassword(r.Context(), user, password)
+	config.Metrics.UserStoreCall("UpgradePassword", time.Since(start), err)
+	if err != nil {
+		config.Log(r, LogStoreFailure, LevelError, "password upgrade failed", Fields{"error": err, "user_id": user.GetID()})
+	}
+}
+
 func MakeToken(r *http.Request, config *Config, user User) *Token {
 	issuedAt := now()
 	return &Token{
@@ -54,7 +104,7 @@ func MakeToken(r *http.Request, config *Config, user User) *Token {
 		UserName:  user.GetName(),
 		IssuedAt:  Timestamp(issuedAt),
 		ExpiredAt: Timestamp(issuedAt.Add(config.TokenExpiration)),
-		ClientIP:  getClientIP(r),
+		ClientIP:  getClientIP(config, r),
 		Claims:    user.GetClaims(),
 	}
 }
@@ -64,16 +114,32 @@ func WriteLoginResponse(w http.ResponseWriter, r *http.Request, config *Config,
 
 	tokenString, err3 := token.Encode(config)
 	if err3 != nil {
-		SendError(w, err3)
+		SendRequestError(config, w, r, err3)
 		return
 	}
+	emitUserEvent(config, r, EventTokenIssued, user)
 
-	SendJSON(w, &LoginResponse{
+	result := &LoginResponse{
 		Token:     tokenString,
 		UserID:    token.UserID,
 		UserName:  token.UserName,
 		ExpiredAt: token.ExpiredAt,
-	})
+	}
+
+	if config.LoginMode != LoginBody {
+		SetTokenCookie(w, config, tokenString, time.Time(token.ExpiredAt))
+		switch config.CSRF {
+		case CSRFDoubleSubmit:
+			result.CSRFToken = SetCSRFCookie(w, r, config)
+		case CSRFSynchronizer:
+			result.CSRFToken = synchronizerToken(config, tokenString)
+		}
+		if config.LoginMode == LoginCookieOnly {
+			result.Token = ""
+		}
+	}
+
+	SendJSON(w, result)
 }
 
 func decodeCredentials(w http.ResponseWriter, r *http.Request) (*Credentials, *Error) {
@@ -1,9 +1,13 @@
 package auth
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 const (
@@ -96,3 +100,131 @@ func TestLoginHandler_InvalidJSON(t *testing.T) {
 		Expect().
 		Status(http.StatusBadRequest)
 }
+
+func TestLoginHandler_CookieMode(t *testing.T) {
+	config := makeTestConfig()
+	config.LoginMode = LoginCookie
+	handler := LoginHandler(config)
+	c := makectx(t, config, httptest.NewServer(handler))
+	r := c.expect.POST("/").
+		WithBasicAuth("bob", "b0b").
+		Expect().
+		Status(http.StatusOK)
+	r.JSON().Schema(loginSchema)
+
+	cookie := r.Cookie(defaultTokenCookie).Raw()
+	assert.NotEmpty(t, cookie.Value)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, "/", cookie.Path)
+	assert.True(t, cookie.MaxAge > 0)
+}
+
+func TestLoginHandler_CookieOnlyMode(t *testing.T) {
+	config := makeTestConfig()
+	config.LoginMode = LoginCookieOnly
+	config.CSRF = CSRFSynchronizer
+	handler := LoginHandler(config)
+	c := makectx(t, config, httptest.NewServer(handler))
+	r := c.expect.POST("/").
+		WithBasicAuth("bob", "b0b").
+		Expect().
+		Status(http.StatusOK)
+
+	token := r.Cookie(defaultTokenCookie).Value().Raw()
+	obj := r.JSON().Object()
+	obj.NotContainsKey("token")
+	obj.Value("csrf_token").String().Equal(synchronizerToken(config, token))
+}
+
+func TestLoginHandler_HostCookiePrefix(t *testing.T) {
+	config := makeTestConfig()
+	config.TokenCookie = "__Host-token"
+	config.LoginMode = LoginCookie
+	config.Cookie.Domain = "test.net"
+	config.Cookie.Path = "/api"
+
+	w := httptest.NewRecorder()
+	SetTokenCookie(w, config, "token", now().Add(time.Hour))
+	cookie := w.Result().Cookies()[0]
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, "/", cookie.Path)
+	assert.Empty(t, cookie.Domain)
+}
+
+func TestCookieOptions_Defaults(t *testing.T) {
+	config := makeTestConfig()
+	config.Cookie = CookieOptions{Domain: "test.net"}
+	config.SetDefaults()
+
+	w := httptest.NewRecorder()
+	SetTokenCookie(w, config, "token", now().Add(time.Hour))
+	cookie := w.Result().Cookies()[0]
+	assert.Equal(t, "test.net", cookie.Domain)
+	assert.Equal(t, "/", cookie.Path)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	config.Cookie.DisableHttpOnly = true
+	w = httptest.NewRecorder()
+	SetTokenCookie(w, config, "token", now().Add(time.Hour))
+	assert.False(t, w.Result().Cookies()[0].HttpOnly)
+}
+
+func TestLogoutHandler(t *testing.T) {
+	config := makeTestConfig()
+	handler := LogoutHandler(config)
+	c := makectx(t, config, httptest.NewServer(handler))
+	cookie := c.expect.POST("/").
+		Expect().
+		Status(http.StatusNoContent).
+		Cookie(defaultTokenCookie).
+		Raw()
+	assert.Empty(t, cookie.Value)
+	assert.True(t, cookie.MaxAge < 0)
+
+	cookie = c.expect.POST("/").
+		WithCookie(defaultCSRFCookie, "csrf").
+		Expect().
+		Status(http.StatusNoContent).
+		Cookie(defaultCSRFCookie).
+		Raw()
+	assert.Empty(t, cookie.Value)
+	assert.True(t, cookie.MaxAge < 0)
+}
+
+type upgradingUserStore struct {
+	testUserStore
+	upgraded map[string]string
+}
+
+func (us *upgradingUserStore) UpgradePassword(ctx context.Context, user User, password string) error {
+	us.upgraded[user.GetName()] = password
+	return nil
+}
+
+func TestLoginHandler_UpgradePassword(t *testing.T) {
+	store := &upgradingUserStore{testUserStore: makeTestUserStore(), upgraded: map[string]string{}}
+	config := (&Config{UserStore: store}).SetDefaults()
+	c := makectx(t, config, httptest.NewServer(LoginHandler(config)))
+
+	c.expect.POST("/").WithJSON(&Credentials{UserName: "bob", Password: "1"}).
+		Expect().
+		Status(http.StatusUnauthorized)
+	assert.Empty(t, store.upgraded)
+
+	c.expect.POST("/").WithJSON(&Credentials{UserName: "bob", Password: "b0b"}).
+		Expect().
+		Status(http.StatusOK)
+	assert.Equal(t, map[string]string{"bob": "b0b"}, store.upgraded)
+}
+
+func TestBasicAuth_DoesNotUpgradePassword(t *testing.T) {
+	store := &upgradingUserStore{testUserStore: makeTestUserStore(), upgraded: map[string]string{}}
+	config := (&Config{UserStore: store}).SetDefaults()
+	c := makectx(t, config, middlewareServer(config))
+
+	c.expect.GET("/data").WithBasicAuth("bob", "b0b").
+		Expect().
+		Status(http.StatusOK)
+	assert.Empty(t, store.upgraded)
+}
@@ -0,0 +1,110 @@
+package memstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// snapshotVersion is version of JSON snapshot format.
+const snapshotVersion = 1
+
+type snapshot struct {
+	Version int       `json:"version"`
+	Users   []*record `json:"users"`
+}
+
+// Save writes JSON snapshot of users with password hashes.
+// OAuth tokens are not saved, only links to accounts are.
+func (s *Store) Save(w io.Writer) error {
+	// encode under the lock, records are mutated in place
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap := snapshot{
+		Version: snapshotVersion,
+		Users:   make([]*record, 0, len(s.users)),
+	}
+	for _, rec := range s.users {
+		snap.Users = append(snap.Users, rec)
+	}
+	sort.Slice(snap.Users, func(i, j int) bool {
+		return snap.Users[i].ID < snap.Users[j].ID
+	})
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&snap)
+}
+
+// Load replaces users of the store with JSON snapshot.
+// Store is left unchanged if snapshot is malformed or violates unique indexes.
+func (s *Store) Load(r io.Reader) error {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("memstore: cannot decode snapshot: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("memstore: unsupported snapshot version %d", snap.Version)
+	}
+
+	next := New(Options{Hasher: s.hasher, AdminRole: s.adminRole})
+	for _, rec := range snap.Users {
+		if rec == nil || len(rec.ID) == 0 {
+			return fmt.Errorf("memstore: snapshot has user without id")
+		}
+		rec.Email = normalizeEmail(rec.Email)
+		if err := next.insert(rec); err != nil {
+			return fmt.Errorf("memstore: user %s: %w", rec.ID, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = next.users
+	s.byName = next.byName
+	s.byEmail = next.byEmail
+	s.accounts = next.accounts
+	return nil
+}
+
+// SaveFile atomically writes snapshot to given file.
+func (s *Store) SaveFile(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	// snapshot contains password hashes
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if err := s.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadFile replaces users of the store with snapshot from given file.
+func (s *Store) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Load(f)
+}
@@ -0,0 +1,568 @@
+// Package memstore implements thread-safe in-memory auth.UserStore and auth.UserStoreEx.
+// It is reference implementation for tests and small deployments, state can be kept in JSON snapshots.
+package memstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocontrib/auth"
+	"github.com/gocontrib/auth/password"
+)
+
+const defaultAdminRole = "admin"
+
+var (
+	// ErrNotFound is returned when user does not exist.
+	ErrNotFound = errors.New("memstore: user not found")
+	// ErrInvalidCredentials is returned when username or password does not match.
+	ErrInvalidCredentials = errors.New("memstore: invalid credentials")
+	// ErrDuplicateID is returned when user with the same ID already exists.
+	ErrDuplicateID = errors.New("memstore: duplicate user id")
+	// ErrDuplicateName is returned when user with the same name already exists
+	// or name is email of another user.
+	ErrDuplicateName = errors.New("memstore: duplicate user name")
+	// ErrDuplicateEmail is returned when user with the same email already exists.
+	ErrDuplicateEmail = errors.New("memstore: duplicate email")
+)
+
+// Options of memory store.
+type Options struct {
+	// Hasher hashes passwords, argon2id by default.
+	// Hashes of other algorithms or parameters are upgraded on successful login, see UpgradePassword.
+	Hasher password.Hasher
+	// AdminRole is role of admin users, 'admin' by default
+	AdminRole string
+}
+
+// Store is in-memory user store, it is safe for concurrent use.
+type Store struct {
+	mu        sync.RWMutex
+	users     map[string]*record // by id
+	byName    map[string]string
+	byEmail   map[string]string
+	accounts  map[accountKey]string
+	hasher    password.Hasher
+	adminRole string
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+type accountKey struct {
+	provider       string
+	providerUserID string
+}
+
+// record is stored state of the user.
+type record struct {
+	User
+	PasswordHash string     `json:"password_hash,omitempty"`
+	Accounts     []*Account `json:"accounts,omitempty"`
+}
+
+// New creates empty store.
+func New(options Options) *Store {
+	s := &Store{
+		hasher:    options.Hasher,
+		adminRole: options.AdminRole,
+	}
+	if s.hasher == nil {
+		s.hasher = password.Default()
+	}
+	if len(s.adminRole) == 0 {
+		s.adminRole = defaultAdminRole
+	}
+	s.reset()
+	return s
+}
+
+func (s *Store) reset() {
+	s.users = make(map[string]*record)
+	s.byName = make(map[string]string)
+	s.byEmail = make(map[string]string)
+	s.accounts = make(map[accountKey]string)
+}
+
+// User stored in memory.
+type User struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Email     string   `json:"email,omitempty"`
+	FirstName string   `json:"first_name,omitempty"`
+	LastName  string   `json:"last_name,omitempty"`
+	NickName  string   `json:"nick_name,omitempty"`
+	AvatarURL string   `json:"avatar_url,omitempty"`
+	Location  string   `json:"location,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	admin     bool
+}
+
+func (u *User) GetID() string {
+	return u.ID
+}
+
+func (u *User) GetName() string {
+	return u.Name
+}
+
+func (u *User) GetEmail() string {
+	return u.Email
+}
+
+func (u *User) IsAdmin() bool {
+	return u.admin
+}
+
+func (u *User) GetClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"roles": u.Roles,
+	}
+}
+
+// Account is OAuth account linked to user.
+// Tokens are kept in memory only, they are not written to snapshots.
+type Account struct {
+	Provider          string    `json:"provider"`
+	ProviderUserID    string    `json:"provider_user_id"`
+	AccessToken       string    `json:"-"`
+	AccessTokenSecret string    `json:"-"`
+	RefreshToken      string    `json:"-"`
+	ExpiresAt         time.Time `json:"-"`
+}
+
+func (s *Store) ValidateCredentials(ctx context.Context, username, pwd string) (auth.User, error) {
+	s.mu.RLock()
+	rec := s.findByName(username)
+	var hash string
+	var user *User
+	if rec != nil {
+		hash = rec.PasswordHash
+		user = s.copyUser(rec)
+	}
+	s.mu.RUnlock()
+
+	// verify outside of the lock, hashing is slow by design
+	if len(hash) == 0 {
+		// spend the same time as for existing user to not reveal valid usernames
+		s.hasher.Verify(pwd, s.dummy())
+		return nil, ErrInvalidCredentials
+	}
+	ok, err := s.hasher.Verify(pwd, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// dummy returns hash verified for unknown users.
+func (s *Store) dummy() string {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = s.hasher.Hash("dummy password")
+	})
+	return s.dummyHash
+}
+
+// UpgradePassword re-hashes verified password if stored hash is legacy or has outdated parameters.
+func (s *Store) UpgradePassword(ctx context.Context, user auth.User, pwd string) error {
+	s.mu.RLock()
+	rec, ok := s.users[user.GetID()]
+	var hash string
+	if ok {
+		hash = rec.PasswordHash
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return ErrNotFound
+	}
+	if len(hash) == 0 || !s.hasher.NeedsRehash(hash) {
+		return nil
+	}
+	newHash, err := s.hasher.Hash(pwd)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// skip if password was changed meanwhile
+	if rec, ok := s.users[user.GetID()]; ok && rec.PasswordHash == hash {
+		rec.PasswordHash = newHash
+	}
+	return nil
+}
+
+func (s *Store) FindUserByID(ctx context.Context, userID string) (auth.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.copyUser(rec), nil
+}
+
+func (s *Store) FindUserByEmail(ctx context.Context, email string) (auth.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, ok := s.byEmail[normalizeEmail(email)]
+	if !ok || len(email) == 0 {
+		return nil, ErrNotFound
+	}
+	return s.copyUser(s.users[id]), nil
+}
+
+// FindUserByAccount returns user linked with given OAuth account.
+func (s *Store) FindUserByAccount(ctx context.Context, provider, providerUserID string) (auth.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, ok := s.accounts[accountKey{provider, providerUserID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.copyUser(s.users[id]), nil
+}
+
+// findByName looks user up by email if username contains '@' or by name otherwise,
+// caller must hold the lock.
+func (s *Store) findByName(username string) *record {
+	if strings.Contains(username, "@") {
+		if id, ok := s.byEmail[normalizeEmail(username)]; ok {
+			return s.users[id]
+		}
+		return nil
+	}
+	if id, ok := s.byName[username]; ok {
+		return s.users[id]
+	}
+	return nil
+}
+
+// copyUser returns snapshot of the user, so callers never share state with the store.
+func (s *Store) copyUser(rec *record) *User {
+	user := rec.User
+	user.Roles = append([]string(nil), rec.Roles...)
+	user.admin = false
+	for _, role := range user.Roles {
+		if role == s.adminRole {
+			user.admin = true
+		}
+	}
+	return &user
+}
+
+// CreateUser creates user from given data and links OAuth account if data has provider.
+// Name is derived from display name, nickname or email and gets numeric suffix if it is taken,
+// so different people with the same display name can sign up.
+// Role of data is ignored, data may come from self-registration; grant roles with AddRole.
+func (s *Store) CreateUser(ctx context.Context, data auth.UserData) (auth.User, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	var hash string
+	if len(data.Password) > 0 {
+		hash, err = s.hasher.Hash(data.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rec := &record{
+		User: User{
+			ID:        id,
+			Name:      firstNonEmpty(data.Name, data.NickName, data.Email),
+			Email:     normalizeEmail(data.Email),
+			FirstName: data.FirstName,
+			LastName:  data.LastName,
+			NickName:  data.NickName,
+			AvatarURL: data.AvatarURL,
+			Location:  data.Location,
+		},
+		PasswordHash: hash,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec.Name = s.uniqueName(rec.Name)
+	if err := s.insert(rec); err != nil {
+		return nil, err
+	}
+	if len(data.Provider) > 0 {
+		s.linkAccount(rec, data)
+	}
+	return s.copyUser(rec), nil
+}
+
+// AddUser adds user with given ID and plain password, empty password disables password login.
+// It is intended to seed the store.
+func (s *Store) AddUser(user *User, pwd string) error {
+	var hash string
+	if len(pwd) > 0 {
+		var err error
+		hash, err = s.hasher.Hash(pwd)
+		if err != nil {
+			return err
+		}
+	}
+	rec := &record{User: *user, PasswordHash: hash}
+	rec.Email = normalizeEmail(rec.Email)
+	rec.Roles = append([]string(nil), user.Roles...)
+	if len(rec.ID) == 0 {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		rec.ID = id
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insert(rec)
+}
+
+// uniqueName returns name or name with numeric suffix that is not taken, caller must hold the lock.
+func (s *Store) uniqueName(name string) string {
+	if len(name) == 0 {
+		name = "user"
+	}
+	candidate := name
+	for i := 2; s.nameTaken(candidate); i++ {
+		candidate = name + "-" + strconv.Itoa(i)
+	}
+	return candidate
+}
+
+// nameTaken reports whether name is name or email of another user, caller must hold the lock.
+func (s *Store) nameTaken(name string) bool {
+	if _, ok := s.byName[name]; ok {
+		return true
+	}
+	_, ok := s.byEmail[normalizeEmail(name)]
+	return ok
+}
+
+// insert adds record to indexes, caller must hold write lock.
+func (s *Store) insert(rec *record) error {
+	if _, ok := s.users[rec.ID]; ok {
+		return ErrDuplicateID
+	}
+	if s.nameTaken(rec.Name) {
+		return ErrDuplicateName
+	}
+	if len(rec.Email) > 0 {
+		if _, ok := s.byEmail[rec.Email]; ok {
+			return ErrDuplicateEmail
+		}
+		if _, ok := s.byName[rec.Email]; ok {
+			return ErrDuplicateEmail
+		}
+		s.byEmail[rec.Email] = rec.ID
+	}
+	s.users[rec.ID] = rec
+	s.byName[rec.Name] = rec.ID
+	for _, a := range rec.Accounts {
+		s.accounts[accountKey{a.Provider, a.ProviderUserID}] = rec.ID
+	}
+	return nil
+}
+
+// UpdateAccount updates profile of the user and links OAuth account if data has provider.
+// Role of data is ignored like in CreateUser.
+func (s *Store) UpdateAccount(ctx context.Context, user auth.User, data auth.UserData) error {
+	var hash string
+	if len(data.Password) > 0 {
+		var err error
+		hash, err = s.hasher.Hash(data.Password)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.users[user.GetID()]
+	if !ok {
+		return ErrNotFound
+	}
+	fields := []struct {
+		field *string
+		value string
+	}{
+		{&rec.FirstName, data.FirstName},
+		{&rec.LastName, data.LastName},
+		{&rec.NickName, data.NickName},
+		{&rec.AvatarURL, data.AvatarURL},
+		{&rec.Location, data.Location},
+	}
+	for _, f := range fields {
+		if len(f.value) > 0 {
+			*f.field = f.value
+		}
+	}
+	if len(hash) > 0 {
+		rec.PasswordHash = hash
+	}
+	if len(data.Provider) > 0 {
+		s.linkAccount(rec, data)
+	}
+	return nil
+}
+
+// linkAccount upserts OAuth account, caller must hold write lock.
+func (s *Store) linkAccount(rec *record, data auth.UserData) {
+	key := accountKey{data.Provider, data.UserID}
+	account := &Account{
+		Provider:          data.Provider,
+		ProviderUserID:    data.UserID,
+		AccessToken:       data.AccessToken,
+		AccessTokenSecret: data.AccessTokenSecret,
+		RefreshToken:      data.RefreshToken,
+		ExpiresAt:         data.ExpiresAt,
+	}
+	// account may be relinked from another user
+	if id, ok := s.accounts[key]; ok {
+		if prev, ok := s.users[id]; ok {
+			prev.Accounts = removeAccount(prev.Accounts, key)
+		}
+	}
+	rec.Accounts = append(removeAccount(rec.Accounts, key), account)
+	s.accounts[key] = rec.ID
+}
+
+func removeAccount(accounts []*Account, key accountKey) []*Account {
+	result := accounts[:0]
+	for _, a := range accounts {
+		if a.Provider != key.provider || a.ProviderUserID != key.providerUserID {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// Accounts returns copies of OAuth accounts linked to given user.
+func (s *Store) Accounts(ctx context.Context, userID string) ([]*Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := make([]*Account, 0, len(rec.Accounts))
+	for _, a := range rec.Accounts {
+		account := *a
+		result = append(result, &account)
+	}
+	return result, nil
+}
+
+// SetPassword replaces password of given user.
+func (s *Store) SetPassword(ctx context.Context, userID, pwd string) error {
+	return s.UpdateAccount(ctx, &User{ID: userID}, auth.UserData{Password: pwd})
+}
+
+// AddRole grants role to given user.
+func (s *Store) AddRole(ctx context.Context, userID, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	addRole(rec, role)
+	return nil
+}
+
+// RemoveRole revokes role from given user.
+func (s *Store) RemoveRole(ctx context.Context, userID, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	roles := rec.Roles[:0]
+	for _, r := range rec.Roles {
+		if r != role {
+			roles = append(roles, r)
+		}
+	}
+	rec.Roles = roles
+	return nil
+}
+
+// DeleteUser removes user and its linked accounts.
+func (s *Store) DeleteUser(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.users, rec.ID)
+	delete(s.byName, rec.Name)
+	if len(rec.Email) > 0 {
+		delete(s.byEmail, rec.Email)
+	}
+	for _, a := range rec.Accounts {
+		delete(s.accounts, accountKey{a.Provider, a.ProviderUserID})
+	}
+	return nil
+}
+
+// Len returns number of users.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.users)
+}
+
+// Close does nothing, store has no resources to release.
+func (s *Store) Close() {
+}
+
+func addRole(rec *record, role string) {
+	for _, r := range rec.Roles {
+		if r == role {
+			return
+		}
+	}
+	rec.Roles = append(rec.Roles, role)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if len(v) > 0 {
+			return v
+		}
+	}
+	return ""
+}
+
+var (
+	_ auth.UserStore   = (*Store)(nil)
+	_ auth.UserStoreEx = (*Store)(nil)
+
+	_ auth.PasswordUpgrader = (*Store)(nil)
+	_ auth.AccountFinder    = (*Store)(nil)
+)
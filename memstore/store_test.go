@@ -0,0 +1,355 @@
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gocontrib/auth"
+	"github.com/gocontrib/auth/password"
+	"github.com/stretchr/testify/assert"
+)
+
+var testHasher = &password.Argon2id{Time: 1, Memory: 1024, Threads: 1}
+
+func newTestStore(t *testing.T) *Store {
+	s := New(Options{Hasher: testHasher})
+	assert.Nil(t, s.AddUser(&User{ID: "1", Name: "bob", Email: "Bob@Example.com"}, "b0b"))
+	assert.Nil(t, s.AddUser(&User{ID: "2", Name: "admin", Roles: []string{"admin"}}, "admin"))
+	return s
+}
+
+func TestValidateCredentials(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	user, err := s.ValidateCredentials(ctx, "bob", "b0b")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", user.GetID())
+	assert.Equal(t, "bob@example.com", user.GetEmail())
+	assert.False(t, user.IsAdmin())
+
+	user, err = s.ValidateCredentials(ctx, "BOB@example.com", "b0b")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", user.GetID())
+
+	user, err = s.ValidateCredentials(ctx, "admin", "admin")
+	assert.Nil(t, err)
+	assert.True(t, user.IsAdmin())
+
+	_, err = s.ValidateCredentials(ctx, "bob", "wrong")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = s.ValidateCredentials(ctx, "nobody", "b0b")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = s.ValidateCredentials(ctx, "nobody@example.com", "b0b")
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestValidateCredentials_NameCannotShadowEmail(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	assert.Equal(t, ErrDuplicateName, s.AddUser(&User{ID: "3", Name: "BOB@example.com"}, "evil"))
+	assert.Equal(t, ErrDuplicateEmail, s.AddUser(&User{ID: "3", Name: "rob", Email: "admin"}, "evil"))
+
+	// names with '@' are never looked up by name
+	assert.Nil(t, s.AddUser(&User{ID: "3", Name: "rob@example.com", Email: "rob@corp"}, "r0b"))
+	_, err := s.ValidateCredentials(ctx, "rob@example.com", "r0b")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = s.ValidateCredentials(ctx, "rob@corp", "r0b")
+	assert.Nil(t, err)
+}
+
+func TestPasswordIsHashed(t *testing.T) {
+	s := newTestStore(t)
+	s.mu.RLock()
+	hash := s.users["1"].PasswordHash
+	s.mu.RUnlock()
+	assert.NotEqual(t, "b0b", hash)
+	ok, err := testHasher.Verify("b0b", hash)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestUniqueIndexes(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	assert.Equal(t, ErrDuplicateID, s.AddUser(&User{ID: "1", Name: "rob"}, ""))
+	assert.Equal(t, ErrDuplicateName, s.AddUser(&User{ID: "3", Name: "bob"}, ""))
+	assert.Equal(t, ErrDuplicateEmail, s.AddUser(&User{ID: "3", Name: "rob", Email: "bob@EXAMPLE.com"}, ""))
+
+	_, err := s.CreateUser(ctx, auth.UserData{Name: "robert", Email: "bob@example.com"})
+	assert.Equal(t, ErrDuplicateEmail, err)
+	assert.Equal(t, 2, s.Len())
+}
+
+func TestCreateUser(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	user, err := s.CreateUser(ctx, auth.UserData{
+		Provider:    "github",
+		UserID:      "gh-42",
+		Email:       "Joe@Example.com",
+		NickName:    "joe",
+		AccessToken: "secret",
+		Password:    "j0e",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "joe", user.GetName())
+	assert.Len(t, user.GetID(), 32)
+
+	found, err := s.FindUserByEmail(ctx, "JOE@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, user.GetID(), found.GetID())
+
+	found, err = s.FindUserByAccount(ctx, "github", "gh-42")
+	assert.Nil(t, err)
+	assert.Equal(t, user.GetID(), found.GetID())
+
+	accounts, err := s.Accounts(ctx, user.GetID())
+	assert.Nil(t, err)
+	assert.Len(t, accounts, 1)
+	assert.Equal(t, "secret", accounts[0].AccessToken)
+
+	_, err = s.ValidateCredentials(ctx, "joe", "j0e")
+	assert.Nil(t, err)
+}
+
+func TestCreateUser_SameDisplayName(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	var names []string
+	for i, data := range []auth.UserData{
+		{Provider: "github", UserID: "1", Name: "Joe Smith", Email: "joe@example.com"},
+		{Provider: "github", UserID: "2", Name: "Joe Smith"},
+		{Provider: "twitter", UserID: "3", Name: "Joe Smith"},
+		{Provider: "twitter", UserID: "4"},
+		{Provider: "twitter", UserID: "5"},
+		{Provider: "github", UserID: "6", Name: "bob"},
+	} {
+		user, err := s.CreateUser(ctx, data)
+		assert.Nil(t, err, i)
+		names = append(names, user.GetName())
+	}
+	assert.Equal(t, []string{"Joe Smith", "Joe Smith-2", "Joe Smith-3", "user", "user-2", "bob-2"}, names)
+
+	found, err := s.FindUserByAccount(ctx, "twitter", "3")
+	assert.Nil(t, err)
+	assert.Equal(t, "Joe Smith-3", found.GetName())
+}
+
+func TestFindUser_NotFound(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	_, err := s.FindUserByID(ctx, "404")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = s.FindUserByEmail(ctx, "")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = s.FindUserByAccount(ctx, "github", "404")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestUpdateAccount(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	bob, _ := s.FindUserByID(ctx, "1")
+
+	err := s.UpdateAccount(ctx, bob, auth.UserData{
+		Provider:  "github",
+		UserID:    "gh-1",
+		FirstName: "Bob",
+		Role:      "admin",
+	})
+	assert.Nil(t, err)
+
+	user, _ := s.FindUserByID(ctx, "1")
+	assert.Equal(t, "Bob", user.(*User).FirstName)
+	// roles are granted only by AddRole
+	assert.False(t, user.IsAdmin())
+
+	// relink account to another user
+	admin, _ := s.FindUserByID(ctx, "2")
+	assert.Nil(t, s.UpdateAccount(ctx, admin, auth.UserData{Provider: "github", UserID: "gh-1"}))
+	found, _ := s.FindUserByAccount(ctx, "github", "gh-1")
+	assert.Equal(t, "2", found.GetID())
+	accounts, _ := s.Accounts(ctx, "1")
+	assert.Len(t, accounts, 0)
+
+	assert.Equal(t, ErrNotFound, s.UpdateAccount(ctx, &User{ID: "404"}, auth.UserData{}))
+}
+
+func TestRegister_IgnoresRole(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	config := (&auth.Config{UserStore: s, UserStoreEx: s}).SetDefaults()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/register",
+		strings.NewReader(`{"name": "eve", "email": "eve@example.com", "role": "admin"}`))
+	auth.RegisterHandler(config).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	user, err := s.FindUserByEmail(ctx, "eve@example.com")
+	assert.Nil(t, err)
+	assert.False(t, user.IsAdmin())
+
+	user, err = s.CreateUser(ctx, auth.UserData{Name: "mallory", Role: "admin"})
+	assert.Nil(t, err)
+	assert.False(t, user.IsAdmin())
+}
+
+func TestRoles(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	assert.Nil(t, s.AddRole(ctx, "1", "admin"))
+	assert.Nil(t, s.AddRole(ctx, "1", "admin"))
+	user, _ := s.FindUserByID(ctx, "1")
+	assert.True(t, user.IsAdmin())
+	assert.Equal(t, []string{"admin"}, user.(*User).Roles)
+
+	// returned user is a copy
+	user.(*User).Roles[0] = "changed"
+	user, _ = s.FindUserByID(ctx, "1")
+	assert.True(t, user.IsAdmin())
+
+	assert.Nil(t, s.RemoveRole(ctx, "1", "admin"))
+	user, _ = s.FindUserByID(ctx, "1")
+	assert.False(t, user.IsAdmin())
+
+	assert.Equal(t, ErrNotFound, s.AddRole(ctx, "404", "admin"))
+}
+
+func TestSetPasswordAndDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	assert.Nil(t, s.SetPassword(ctx, "1", "new"))
+	_, err := s.ValidateCredentials(ctx, "bob", "b0b")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = s.ValidateCredentials(ctx, "bob", "new")
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.DeleteUser(ctx, "1"))
+	_, err = s.FindUserByEmail(ctx, "bob@example.com")
+	assert.Equal(t, ErrNotFound, err)
+	// name and email are free again
+	assert.Nil(t, s.AddUser(&User{ID: "3", Name: "bob", Email: "bob@example.com"}, ""))
+}
+
+func TestUpgradePassword(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	bcrypt := &password.Bcrypt{Cost: 4}
+	legacy, err := bcrypt.Hash("b0b")
+	assert.Nil(t, err)
+	s.mu.Lock()
+	s.users["1"].PasswordHash = legacy
+	s.mu.Unlock()
+
+	user, err := s.ValidateCredentials(ctx, "bob", "b0b")
+	assert.Nil(t, err)
+	assert.Nil(t, s.UpgradePassword(ctx, user, "b0b"))
+
+	s.mu.RLock()
+	hash := s.users["1"].PasswordHash
+	s.mu.RUnlock()
+	assert.NotEqual(t, legacy, hash)
+	assert.False(t, testHasher.NeedsRehash(hash))
+
+	_, err = s.ValidateCredentials(ctx, "bob", "b0b")
+	assert.Nil(t, err)
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	bob, _ := s.FindUserByID(ctx, "1")
+	assert.Nil(t, s.UpdateAccount(ctx, bob, auth.UserData{Provider: "github", UserID: "gh-1", AccessToken: "secret"}))
+
+	var buf bytes.Buffer
+	assert.Nil(t, s.Save(&buf))
+	assert.NotContains(t, buf.String(), "secret")
+	assert.NotContains(t, buf.String(), "b0b")
+
+	loaded := New(Options{Hasher: testHasher})
+	assert.Nil(t, loaded.Load(&buf))
+	assert.Equal(t, 2, loaded.Len())
+
+	user, err := loaded.ValidateCredentials(ctx, "bob", "b0b")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", user.GetID())
+	user, _ = loaded.ValidateCredentials(ctx, "admin", "admin")
+	assert.True(t, user.IsAdmin())
+	user, err = loaded.FindUserByAccount(ctx, "github", "gh-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", user.GetID())
+}
+
+func TestLoad_KeepsStateOnError(t *testing.T) {
+	s := newTestStore(t)
+
+	for _, data := range []string{
+		`{"version":1,"users":[`,
+		`{"version":2,"users":[]}`,
+		`{"version":1,"users":[{"name":"noid"}]}`,
+		`{"version":1,"users":[{"id":"1","name":"a"},{"id":"2","name":"a"}]}`,
+	} {
+		assert.NotNil(t, s.Load(bytes.NewBufferString(data)), data)
+		assert.Equal(t, 2, s.Len())
+	}
+}
+
+func TestSaveFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "memstore")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "users.json")
+
+	s := newTestStore(t)
+	assert.Nil(t, s.SaveFile(path))
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1)
+
+	loaded := New(Options{Hasher: testHasher})
+	assert.Nil(t, loaded.LoadFile(path))
+	assert.Equal(t, 2, loaded.Len())
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user, err := s.CreateUser(ctx, auth.UserData{Name: fmt.Sprintf("user%d", i)})
+			assert.Nil(t, err)
+			assert.Nil(t, s.AddRole(ctx, user.GetID(), "staff"))
+			_, err = s.FindUserByID(ctx, user.GetID())
+			assert.Nil(t, err)
+			assert.Nil(t, s.Save(ioutil.Discard))
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 10, s.Len())
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// Login and token validation outcomes reported to Metrics.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	ResultValid    = "valid"
+)
+
+// Metrics receives authentication measurements.
+// See metrics subpackage for Prometheus implementation.
+type Metrics interface {
+	// LoginAttempt counts login attempt with outcome and error code of failure
+	LoginAttempt(outcome, code string)
+	// TokenValidation counts token validation with ResultValid or error code
+	TokenValidation(result string)
+	// UserStoreCall observes latency of UserStore method call
+	UserStoreCall(method string, d time.Duration, err error)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) LoginAttempt(outcome, code string)                       {}
+func (nopMetrics) TokenValidation(result string)                           {}
+func (nopMetrics) UserStoreCall(method string, d time.Duration, err error) {}
+
+func recordLoginAttempt(config *Config, err *Error) {
+	if err != nil {
+		config.Metrics.LoginAttempt(OutcomeFailure, err.Code)
+	} else {
+		config.Metrics.LoginAttempt(OutcomeSuccess, "")
+	}
+}
+
+func recordTokenValidation(config *Config, err *Error) {
+	if err != nil {
+		config.Metrics.TokenValidation(err.Code)
+	} else {
+		config.Metrics.TokenValidation(ResultValid)
+	}
+}
+
+func callValidateCredentials(config *Config, ctx context.Context, username, password string) (User, error) {
+	start := time.Now()
+	user, err := config.UserStore.ValidateCredentials(ctx, username, password)
+	config.Metrics.UserStoreCall("ValidateCredentials", time.Since(start), err)
+	return user, err
+}
+
+func callFindUserByID(config *Config, ctx context.Context, userID string) (User, error) {
+	start := time.Now()
+	user, err := config.UserStore.FindUserByID(ctx, userID)
+	config.Metrics.UserStoreCall("FindUserByID", time.Since(start), err)
+	return user, err
+}
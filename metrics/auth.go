@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"time"
+)
+
+// AuthMetrics collects authentication metrics, implements auth.Metrics.
+type AuthMetrics struct {
+	logins    *CounterVec
+	tokens    *CounterVec
+	userStore *HistogramVec
+}
+
+// NewAuthMetrics registers authentication metrics in given registry.
+func NewAuthMetrics(r *Registry) *AuthMetrics {
+	return &AuthMetrics{
+		logins: r.Counter("auth_login_attempts_total",
+			"Number of login attempts by outcome and error code.", "outcome", "code"),
+		tokens: r.Counter("auth_token_validations_total",
+			"Number of token validations by result.", "result"),
+		userStore: r.Histogram("auth_user_store_duration_seconds",
+			"Latency of user store calls.", DefaultBuckets, "method", "result"),
+	}
+}
+
+// LoginAttempt counts login attempt.
+func (m *AuthMetrics) LoginAttempt(outcome, code string) {
+	m.logins.Inc(outcome, code)
+}
+
+// TokenValidation counts token validation.
+func (m *AuthMetrics) TokenValidation(result string) {
+	m.tokens.Inc(result)
+}
+
+// UserStoreCall observes latency of user store call.
+func (m *AuthMetrics) UserStoreCall(method string, d time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.userStore.Observe(d.Seconds(), method, result)
+}
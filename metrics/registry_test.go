@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("requests_total", "Number of requests.", "code")
+	c.Inc("200")
+	c.Inc("200")
+	c.Add(3, `say "hi"`)
+
+	h := r.Histogram("latency_seconds", "Latency.", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+
+	r.GaugeFunc("idle", "Idle connections.", func() float64 { return 4 })
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, r.Write(buf))
+	assert.Equal(t, `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{code="200"} 2
+requests_total{code="say \"hi\""} 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 2
+latency_seconds_sum 0.55
+latency_seconds_count 2
+# HELP idle Idle connections.
+# TYPE idle gauge
+idle 4
+`, buf.String())
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	m := NewAuthMetrics(r)
+	m.LoginAttempt("failure", "AUTH-BAD-CREDENTIALS")
+	m.TokenValidation("valid")
+	m.UserStoreCall("FindUserByID", 10*time.Millisecond, nil)
+	m.UserStoreCall("FindUserByID", time.Millisecond, errors.New("not found"))
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain"))
+	assert.Contains(t, body, `auth_login_attempts_total{outcome="failure",code="AUTH-BAD-CREDENTIALS"} 1`)
+	assert.Contains(t, body, `auth_token_validations_total{result="valid"} 1`)
+	assert.Contains(t, body, `auth_user_store_duration_seconds_count{method="FindUserByID",result="ok"} 1`)
+	assert.Contains(t, body, `auth_user_store_duration_seconds_count{method="FindUserByID",result="error"} 1`)
+}
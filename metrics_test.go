@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocontrib/auth/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ Metrics = metrics.NewAuthMetrics(metrics.NewRegistry())
+
+type metricsRecorder struct {
+	sync.Mutex
+	logins     []string
+	tokens     []string
+	storeCalls []string
+}
+
+func (m *metricsRecorder) LoginAttempt(outcome, code string) {
+	m.Lock()
+	defer m.Unlock()
+	m.logins = append(m.logins, outcome+":"+code)
+}
+
+func (m *metricsRecorder) TokenValidation(result string) {
+	m.Lock()
+	defer m.Unlock()
+	m.tokens = append(m.tokens, result)
+}
+
+func (m *metricsRecorder) UserStoreCall(method string, d time.Duration, err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.storeCalls = append(m.storeCalls, method)
+}
+
+func TestMetrics(t *testing.T) {
+	rec := &metricsRecorder{}
+	config := makeTestConfig()
+	config.Metrics = rec
+
+	c := makectx(t, config, httptest.NewServer(LoginHandler(config)))
+	c.expect.POST("/").WithBasicAuth("bob", "1").Expect().Status(http.StatusUnauthorized)
+	c.expect.POST("/").WithBasicAuth("bob", "b0b").Expect().Status(http.StatusOK)
+
+	c = makectx(t, config, middlewareServer(config))
+	token := c.makeToken("bob", "b0b")
+	c.expect.GET("/data").WithQuery(defaultTokenKey, token).Expect().Status(http.StatusOK)
+	c.expect.GET("/data").WithQuery(defaultTokenKey, "invalid").Expect().Status(http.StatusUnauthorized)
+
+	assert.Equal(t, []string{"failure:" + ErrBadCredentials.Code, "success:"}, rec.logins)
+	assert.Equal(t, []string{ResultValid, ErrInvalidToken.Code}, rec.tokens)
+	assert.Equal(t, []string{"ValidateCredentials", "ValidateCredentials", "FindUserByID"}, rec.storeCalls)
+}
@@ -2,10 +2,10 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
-
-	log "github.com/sirupsen/logrus"
+	"time"
 )
 
 const (
@@ -45,54 +45,93 @@ type middleware struct {
 
 // ServeHTTP implementation.
 func (m *middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx, err := m.authenticate(r)
+	ctx, err := m.authenticate(w, r)
 	if err == nil {
 		if ctx != nil && ctx != r.Context() {
 			r = r.WithContext(ctx)
 		}
 		m.next.ServeHTTP(w, r)
 	} else {
-		log.Errorf("AUTH ERROR: %v", err)
-		SendError(w, err)
+		SendRequestError(m.config, w, r, withChallenges(m.config, err))
 	}
 }
 
-// Validates auth header or auth_token.
-func (m *middleware) authenticate(r *http.Request) (context.Context, *Error) {
+// Validates basic auth header or JWT token from configured sources.
+func (m *middleware) authenticate(w http.ResponseWriter, r *http.Request) (context.Context, *Error) {
+	// request authenticated by outer middleware, e.g. RequireAdmin nested in RequireUser,
+	// is not validated again, so credentials are checked and login events are emitted once
+	if info := GetAuthInfo(r.Context()); info != nil {
+		return m.validateUser(r, info)
+	}
+
 	var h = r.Header.Get(authorizationHeader)
 	if len(h) > 0 {
-		return m.validateHeader(r, h)
+		scheme, _, err := parseAuthorizationHeader(h)
+		if err != nil {
+			return nil, err
+		}
+		if scheme == schemeBasic {
+			if m.config.DisableBasicAuth {
+				return nil, ErrUnsupportedAuthScheme
+			}
+			return m.validateBasicAuth(r)
+		}
 	}
 
-	cookie, err := r.Cookie(m.config.TokenCookie)
-	if err == nil && cookie != nil {
-		return m.validateJWT(r, cookie.Value)
+	ticket, user, err := redeemTicket(m.config, r)
+	if err != nil {
+		emitTokenRejected(m.config, r, nil, err)
+		return nil, err
+	}
+	if ticket != nil {
+		return m.validateToken(r, ticket, user, AuthMethodTicket)
 	}
 
-	// from query string
-	token := r.URL.Query().Get(m.config.TokenKey)
+	token, method, err := extractToken(m.config, r)
+	if err != nil {
+		return nil, err
+	}
 	if len(token) > 0 {
-		return m.validateJWT(r, token)
+		// invalid tokens are rejected as unauthorized before CSRF check
+		ctx, err := m.validateJWT(r, token, method)
+		if err != nil {
+			return nil, err
+		}
+		if method == AuthMethodCookie {
+			err = checkCSRF(m.config, w, r, token)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if method == AuthMethodWebSocketProtocol {
+			if protocol := selectWebSocketProtocol(r, m.config.WebSocketProtocolPrefix+token); len(protocol) > 0 {
+				ctx = context.WithValue(ctx, webSocketProtocolKey, protocol)
+			}
+		}
+		return ctx, nil
 	}
 
 	return nil, ErrBadAuthorizationHeader
 }
 
-// Validates authorization header.
-func (m *middleware) validateHeader(r *http.Request, auth string) (context.Context, *Error) {
-	scheme, token, err := parseAuthorizationHeader(auth)
+// Authenticate validates Authorization header of given request and returns context with authenticated user.
+// It skips cookies and CSRF checks, so it is intended for non-browser transports like gRPC.
+func Authenticate(config *Config, r *http.Request, requireAdmin bool) (context.Context, *Error) {
+	m := &middleware{
+		config:       config,
+		requireAdmin: requireAdmin,
+	}
+	scheme, token, err := parseAuthorizationHeader(r.Header.Get(authorizationHeader))
 	if err != nil {
 		return nil, err
 	}
-
-	switch scheme {
-	case schemeBasic:
+	if scheme == schemeBasic {
+		if config.DisableBasicAuth {
+			return nil, ErrUnsupportedAuthScheme
+		}
 		return m.validateBasicAuth(r)
-	case schemeBearer:
-		return m.validateJWT(r, token)
-	default:
-		return nil, ErrUnsupportedAuthScheme
 	}
+	return m.validateJWT(r, token, AuthMethodBearer)
 }
 
 func parseAuthorizationHeader(auth string) (scheme string, token string, err *Error) {
@@ -123,47 +162,77 @@ func (m *middleware) validateBasicAuth(r *http.Request) (context.Context, *Error
 		return nil, ErrBadAuthorizationHeader
 	}
 
-	user, err := m.config.UserStore.ValidateCredentials(r.Context(), username, password)
+	user, err := validateCredentials(m.config, r, username, password)
 	if err != nil {
-		return nil, ErrBadCredentials.WithCause(err)
+		return nil, err
 	}
+	emitUserEvent(m.config, r, EventLoginSuccess, user)
 
-	return m.validateUser(r, user)
+	return m.validateUser(r, &AuthInfo{
+		User:     user,
+		Method:   AuthMethodBasic,
+		AuthTime: now(),
+	})
 }
 
-func (m *middleware) validateJWT(r *http.Request, tokenString string) (context.Context, *Error) {
-	_, user, err := validateJWT(m.config, r, tokenString)
+func (m *middleware) validateJWT(r *http.Request, tokenString string, method AuthMethod) (context.Context, *Error) {
+	token, user, err := validateJWT(m.config, r, tokenString)
 	if err != nil {
+		emitTokenRejected(m.config, r, token, err)
 		return nil, err
 	}
 
-	return m.validateUser(r, user)
+	return m.validateToken(r, token, user, method)
 }
 
-func validateJWT(config *Config, r *http.Request, tokenString string) (*Token, User, *Error) {
-	ip := getClientIP(r)
-	if ip == "127.0.0.1" && len(r.Header.Get("X-Forwarded-For")) > 0 {
-		ip = ""
+func (m *middleware) validateToken(r *http.Request, token *Token, user User, method AuthMethod) (context.Context, *Error) {
+	info := &AuthInfo{
+		User:     user,
+		Token:    token,
+		Method:   method,
+		AuthTime: time.Time(token.IssuedAt),
+	}
+	if token.Actor != nil {
+		actor, err := checkImpersonation(m.config, r, token, user)
+		if err != nil {
+			emitTokenRejected(m.config, r, token, err)
+			return nil, err
+		}
+		info.Actor = actor
 	}
-	token, err := parseToken(config, tokenString, ip, false)
+	return m.validateUser(r, info)
+}
+
+func validateJWT(config *Config, r *http.Request, tokenString string) (*Token, User, *Error) {
+	token, user, err := checkJWT(config, r, tokenString)
+	recordTokenValidation(config, err)
+	return token, user, err
+}
+
+// checkJWT returns parsed token along with error if the token is signed but rejected.
+func checkJWT(config *Config, r *http.Request, tokenString string) (*Token, User, *Error) {
+	token, err := parseToken(config, tokenString, getClientIP(config, r), false)
 	if err != nil {
-		return nil, nil, err
+		return token, nil, err
 	}
 
-	user, error := config.UserStore.FindUserByID(r.Context(), token.UserID)
+	user, error := callFindUserByID(config, r.Context(), token.UserID)
 	if error != nil {
-		return nil, nil, ErrUserNotFound.WithCause(error)
+		return token, nil, ErrUserNotFound.WithCause(error)
 	}
 
 	return token, user, nil
 }
 
-func (m *middleware) validateUser(r *http.Request, user User) (context.Context, *Error) {
-	err := m.checkUser(user)
+func (m *middleware) validateUser(r *http.Request, info *AuthInfo) (context.Context, *Error) {
+	err := m.checkUser(info.User)
 	if err != nil {
+		if errors.Is(err, ErrNotAdmin) {
+			emitUserEvent(m.config, r, EventAdminDenied, info.User)
+		}
 		return nil, err
 	}
-	return WithUser(r.Context(), user), nil
+	return WithAuthInfo(r.Context(), info), nil
 }
 
 func (m *middleware) checkUser(user User) *Error {
@@ -33,6 +33,44 @@ func TestBasicAuth_NoAdminPrivileges(t *testing.T) {
 	c.expect.GET("/admin/data").WithBasicAuth("bob", "b0b").Expect().Status(http.StatusForbidden)
 }
 
+func TestBasicAuth_Disabled(t *testing.T) {
+	config := makeTestConfig()
+	config.DisableBasicAuth = true
+	c := makectx(t, config, middlewareServer(config))
+	c.expect.GET("/data").WithBasicAuth("bob", "b0b").Expect().Status(http.StatusUnauthorized)
+}
+
+func TestChallenge_NoCredentials(t *testing.T) {
+	config := makeTestConfig()
+	config.Realm = "test"
+	c := makectx(t, config, middlewareServer(config))
+	r := c.expect.GET("/data").Expect().Status(http.StatusUnauthorized).Raw()
+	assert.Equal(t, []string{`Bearer realm="test"`, `Basic realm="test"`}, r.Header["Www-Authenticate"])
+}
+
+func TestChallenge_InvalidToken(t *testing.T) {
+	config := makeTestConfig()
+	config.DisableBasicAuth = true
+	c := makectx(t, config, middlewareServer(config))
+	r := c.expect.GET("/data").
+		WithHeader(authorizationHeader, "Bearer invalid").
+		Expect().
+		Status(http.StatusUnauthorized).
+		Raw()
+	assert.Equal(t, []string{
+		`Bearer realm="Restricted", error="invalid_token", error_description="User token is invalid, please re-authenticate"`,
+	}, r.Header["Www-Authenticate"])
+}
+
+func TestChallenge_InsufficientScope(t *testing.T) {
+	config := makeTestConfig()
+	c := makectx(t, config, middlewareServer(config))
+	r := c.expect.GET("/admin/data").WithBasicAuth("bob", "b0b").Expect().Status(http.StatusForbidden).Raw()
+	assert.Equal(t, []string{
+		`Bearer realm="Restricted", error="insufficient_scope", error_description="You need admin privileges to make this API call"`,
+	}, r.Header["Www-Authenticate"])
+}
+
 func TestJWT_Valid(t *testing.T) {
 	testJWT(t, "/data", "bob", "b0b", http.StatusOK)
 }
@@ -116,6 +154,7 @@ func middlewareServer(config *Config) *httptest.Server {
 	}
 
 	r.Get("/data", emptyHandler)
+	r.Post("/form", emptyHandler)
 
 	ar := chi.NewRouter()
 	ar.Use(RequireAdmin(config))
@@ -1,8 +1,6 @@
 package main
 
 import (
-	"context"
-	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -11,6 +9,7 @@ import (
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
 	"github.com/gocontrib/auth"
+	"github.com/gocontrib/auth/memstore"
 	"github.com/gocontrib/auth/oauth"
 	"github.com/gocontrib/request"
 	"github.com/gorilla/handlers"
@@ -53,9 +52,11 @@ func makeAPIHandler() http.Handler {
 	})
 	r.Use(cors.Handler)
 
+	// single store serves both interfaces, so users created via OAuth can be found by token
+	store := memstore.New(memstore.Options{})
 	authConfig := &auth.Config{
-		UserStore:   &memStore{},
-		UserStoreEx: &memStore{},
+		UserStore:   store,
+		UserStoreEx: store,
 	}
 	authConfig = authConfig.SetDefaults()
 
@@ -73,47 +74,3 @@ func makeAPIHandler() http.Handler {
 func logMiddleware(next http.Handler) http.Handler {
 	return handlers.LoggingHandler(os.Stdout, next)
 }
-
-type memStore map[string]*auth.UserInfo
-
-func (m memStore) ValidateCredentials(ctx context.Context, username, password string) (auth.User, error) {
-	u, ok := m[username]
-	if !ok {
-		return nil, errors.New("user not found")
-	}
-	if u.Pwd != password {
-		return nil, errors.New("invalid password")
-	}
-	return u, nil
-}
-
-func (m memStore) FindUserByID(ctx context.Context, userID string) (auth.User, error) {
-	for _, u := range m {
-		if u.ID == userID || u.Email == userID {
-			return u, nil
-		}
-	}
-	return nil, errors.New("user not found")
-}
-
-func (m memStore) Close() {
-}
-
-func (m memStore) FindUserByEmail(ctx context.Context, userID string) (auth.User, error) {
-	return m.FindUserByID(ctx, userID)
-}
-
-func (m memStore) CreateUser(ctx context.Context, data auth.UserData) (auth.User, error) {
-	user := &auth.UserInfo{
-		ID:    data.UserID,
-		Name:  data.Name,
-		Email: data.Email,
-		Pwd:   "123",
-	}
-	m[user.Name] = user
-	return user, nil
-}
-
-func (m memStore) UpdateAccount(ctx context.Context, user auth.User, data auth.UserData) error {
-	return nil
-}
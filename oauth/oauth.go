@@ -1,19 +1,19 @@
 package oauth
 
 import (
+	"context"
 	"fmt"
+	"html"
 	"net/http"
 	"os"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/go-chi/chi"
 	"github.com/gocontrib/auth"
-	"github.com/gocontrib/request"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
-	log "github.com/sirupsen/logrus"
 )
 
 type providerFactory = func(clientKey, secret, callbackURL string, scopes ...string) goth.Provider
@@ -25,6 +25,7 @@ var providerNames []string
 // import "github.com/markbates/goth/providers/vk"
 // oauth.WithProviders("vk", vk.New)
 func WithProviders(config *auth.Config, providers ...interface{}) {
+	config = config.SetDefaults()
 	providerNames = []string{}
 	var gothProviders []goth.Provider
 	for i := 0; i+1 < len(providers); i += 2 {
@@ -39,7 +40,7 @@ func WithProviders(config *auth.Config, providers ...interface{}) {
 	if len(gothProviders) > 0 {
 		goth.UseProviders(gothProviders...)
 	} else {
-		log.Warning("no oauth providers")
+		config.Log(nil, auth.LogOAuthProvider, auth.LevelWarn, "no oauth providers", nil)
 	}
 }
 
@@ -65,7 +66,7 @@ func makeProvider(config *auth.Config, provider string, factory providerFactory)
 	key := os.Getenv(p + "_KEY")
 	secret := os.Getenv(p + "_SECRET")
 	if len(key) == 0 || len(secret) == 0 {
-		log.Warningf("%s has no keys\n", provider)
+		config.Log(nil, auth.LogOAuthProvider, auth.LevelWarn, "oauth provider has no keys", auth.Fields{"provider": provider})
 		return nil
 	}
 
@@ -73,7 +74,10 @@ func makeProvider(config *auth.Config, provider string, factory providerFactory)
 	secure := true
 	baseURL := strings.TrimRight(getBaseURL(config, secure), "/")
 	callbackURL := baseURL + "/api/oauth/callback/" + provider
-	log.Debugf("%s callback: %s\n", provider, callbackURL)
+	config.Log(nil, auth.LogOAuthProvider, auth.LevelDebug, "oauth provider callback", auth.Fields{
+		"provider": provider,
+		"callback": callbackURL,
+	})
 
 	// TODO support scopes via env
 	return factory(key, secret, callbackURL)
@@ -125,7 +129,7 @@ func RegisterAPI(r Router, config *auth.Config) {
 
 	defaultGetProviderName := gothic.GetProviderName
 	gothic.GetProviderName = func(r *http.Request) (string, error) {
-		providerName := chi.URLParam(r, "provider")
+		providerName := providerFromPath(r)
 		if len(providerName) > 0 {
 			return providerName, nil
 		}
@@ -134,12 +138,12 @@ func RegisterAPI(r Router, config *auth.Config) {
 
 	r.Get("/api/oauth/success", func(w http.ResponseWriter, r *http.Request) {
 		// TODO print nice html page
-		fmt.Fprintf(w, "<body>Hey, buddy!</body>")
+		writePage(w, config.Messages.Translate(r, auth.MessageOAuthSuccess, "Hey, buddy!"))
 	})
 
 	r.Get("/api/oauth/error", func(w http.ResponseWriter, r *http.Request) {
 		// TODO print nice html error page
-		fmt.Fprintf(w, "<body>Oops, your OAuth failed! Please try again later</body>")
+		writePage(w, config.Messages.Translate(r, auth.MessageOAuthError, "Oops, your OAuth failed! Please try again later"))
 	})
 
 	r.Get("/api/oauth/providers", func(w http.ResponseWriter, r *http.Request) {
@@ -197,7 +201,7 @@ func completeOAuthFlow(w http.ResponseWriter, r *http.Request, config *auth.Conf
 		ExpiresAt:         account.ExpiresAt,
 	}
 
-	user, err := userStore.FindUserByEmail(ctx, account.Email)
+	user, err := findUser(ctx, userStore, account)
 	if err != nil {
 		user, err = userStore.CreateUser(ctx, data)
 		if err != nil {
@@ -211,6 +215,12 @@ func completeOAuthFlow(w http.ResponseWriter, r *http.Request, config *auth.Conf
 		oauthError(w, r, err)
 		return
 	}
+	auth.EmitEvent(config, r, auth.Event{
+		Type:     auth.EventOAuthLink,
+		UserID:   user.GetID(),
+		UserName: user.GetName(),
+		Provider: account.Provider,
+	})
 
 	token := auth.MakeToken(r, config, user)
 	tokenString, err3 := token.Encode(config)
@@ -218,13 +228,49 @@ func completeOAuthFlow(w http.ResponseWriter, r *http.Request, config *auth.Conf
 		oauthError(w, r, err3)
 		return
 	}
+	auth.EmitEvent(config, r, auth.Event{
+		Type:     auth.EventTokenIssued,
+		UserID:   user.GetID(),
+		UserName: user.GetName(),
+		Provider: account.Provider,
+	})
 
-	request.SetCookie(w, r, config.TokenCookie, tokenString)
+	auth.SetTokenCookie(w, config, tokenString, time.Time(token.ExpiredAt))
 
 	// TODO support return_url, absolute url if needed
 	http.Redirect(w, r, "/api/oauth/success?token="+tokenString, http.StatusFound)
 }
 
+// findUser finds user linked with the account, or user with the same email.
+func findUser(ctx context.Context, userStore auth.UserStoreEx, account goth.User) (auth.User, error) {
+	if finder, ok := userStore.(auth.AccountFinder); ok {
+		user, err := finder.FindUserByAccount(ctx, account.Provider, account.UserID)
+		if err == nil {
+			return user, nil
+		}
+	}
+	return userStore.FindUserByEmail(ctx, account.Email)
+}
+
+// providerFromPath reads provider from /login/{provider}, /logout/{provider} and
+// /callback/{provider} paths, so routes can be registered with any router.
+func providerFromPath(r *http.Request) string {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	switch parts[len(parts)-2] {
+	case "login", "logout", "callback":
+		return parts[len(parts)-1]
+	}
+	return ""
+}
+
+func writePage(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<body>%s</body>", html.EscapeString(message))
+}
+
 func oauthError(w http.ResponseWriter, r *http.Request, err error) {
 	http.Redirect(w, r, "/api/oauth/error?message="+err.Error(), http.StatusInternalServerError)
 }
@@ -0,0 +1,104 @@
+package password
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2Prefix = "$argon2id$"
+
+// bounds of argon2id parameters accepted from stored hashes, so crafted hash cannot exhaust memory or CPU
+const (
+	maxArgon2Memory = 1 << 20 // 1 GiB in KiB
+	maxArgon2Time   = 100
+)
+
+// Argon2id hasher, zero fields take OWASP recommended defaults.
+type Argon2id struct {
+	Time    uint32
+	Memory  uint32 // in KiB
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+func init() {
+	RegisterVerifier(argon2Prefix, verifyArgon2id)
+}
+
+func (a *Argon2id) params() Argon2id {
+	p := *a
+	if p.Time == 0 {
+		p.Time = 3
+	}
+	if p.Memory == 0 {
+		p.Memory = 64 * 1024
+	}
+	if p.Threads == 0 {
+		p.Threads = 2
+	}
+	if p.KeyLen == 0 {
+		p.KeyLen = 32
+	}
+	if p.SaltLen == 0 {
+		p.SaltLen = 16
+	}
+	return p
+}
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	p := a.params()
+	salt := make([]byte, p.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version,
+		p.Memory, p.Time, p.Threads, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (a *Argon2id) Verify(password, encoded string) (bool, error) {
+	return Verify(password, encoded)
+}
+
+func (a *Argon2id) NeedsRehash(encoded string) bool {
+	h, p, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	want := a.params()
+	return h.version != strconv.Itoa(argon2.Version) ||
+		p.Time != want.Time || p.Memory != want.Memory || p.Threads != want.Threads ||
+		uint32(len(h.hash)) != want.KeyLen || uint32(len(h.salt)) != want.SaltLen
+}
+
+func decodeArgon2id(encoded string) (*phc, Argon2id, error) {
+	var p Argon2id
+	h, err := parsePHC(encoded)
+	if err != nil || h.id != "argon2id" {
+		return nil, p, ErrMalformedHash
+	}
+	m, err1 := strconv.ParseUint(h.params["m"], 10, 32)
+	t, err2 := strconv.ParseUint(h.params["t"], 10, 32)
+	threads, err3 := strconv.ParseUint(h.params["p"], 10, 8)
+	if err1 != nil || err2 != nil || err3 != nil || len(h.hash) == 0 {
+		return nil, p, ErrMalformedHash
+	}
+	if m == 0 || m > maxArgon2Memory || t == 0 || t > maxArgon2Time || threads == 0 {
+		return nil, p, ErrMalformedHash
+	}
+	p.Memory, p.Time, p.Threads = uint32(m), uint32(t), uint8(threads)
+	return h, p, nil
+}
+
+func verifyArgon2id(password, encoded string) (bool, error) {
+	h, p, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(password), h.salt, p.Time, p.Memory, p.Threads, uint32(len(h.hash)))
+	return equal(key, h.hash), nil
+}
@@ -0,0 +1,53 @@
+package password
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Bcrypt hasher, zero Cost means bcrypt.DefaultCost.
+type Bcrypt struct {
+	Cost int
+}
+
+func init() {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		RegisterVerifier(prefix, verifyBcrypt)
+	}
+}
+
+func (b *Bcrypt) cost() int {
+	if b.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return b.Cost
+}
+
+func (b *Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost())
+	return string(hash), err
+}
+
+func (b *Bcrypt) Verify(password, encoded string) (bool, error) {
+	return Verify(password, encoded)
+}
+
+func (b *Bcrypt) NeedsRehash(encoded string) bool {
+	if !strings.HasPrefix(encoded, "$2") {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != b.cost()
+}
+
+func verifyBcrypt(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, ErrMalformedHash
+	}
+	return true, nil
+}
@@ -0,0 +1,273 @@
+package password
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Legacy formats are verified only to migrate users from other systems,
+// Hasher.NeedsRehash is always true for them.
+func init() {
+	RegisterVerifier("$1$", verifyMD5Crypt)
+	RegisterVerifier("$apr1$", verifyMD5Crypt)
+	RegisterVerifier("$6$", verifySHA512Crypt)
+	RegisterVerifier("pbkdf2_sha256$", verifyDjango)
+	RegisterVerifier("pbkdf2_sha1$", verifyDjango)
+	RegisterVerifier("{SSHA}", verifySSHA)
+	RegisterVerifier("{SHA}", verifySHA)
+}
+
+// maxLegacyRounds bounds work factor of legacy hashes, so crafted hash cannot make verification hang.
+const maxLegacyRounds = 10000000
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// cryptEncode encodes bytes in groups of three with crypt(3) base64 alphabet.
+func cryptEncode(b []byte, groups [][3]int, tail int) string {
+	var sb strings.Builder
+	write := func(w uint, n int) {
+		for i := 0; i < n; i++ {
+			sb.WriteByte(cryptAlphabet[w&0x3f])
+			w >>= 6
+		}
+	}
+	for _, g := range groups {
+		write(uint(b[g[0]])<<16|uint(b[g[1]])<<8|uint(b[g[2]]), 4)
+	}
+	write(uint(b[tail]), (8+5)/6)
+	return sb.String()
+}
+
+// verifyMD5Crypt checks $1$salt$hash hashes and Apache $apr1$salt$hash variant.
+func verifyMD5Crypt(password, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 {
+		return false, ErrMalformedHash
+	}
+	magic := "$" + parts[1] + "$"
+	return equal([]byte(md5Crypt([]byte(password), []byte(parts[2]), magic)), []byte(encoded)), nil
+}
+
+func md5Crypt(password, salt []byte, magic string) string {
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+
+	alt := md5.New()
+	alt.Write(password)
+	alt.Write(salt)
+	alt.Write(password)
+	altSum := alt.Sum(nil)
+
+	ctx := md5.New()
+	ctx.Write(password)
+	ctx.Write([]byte(magic))
+	ctx.Write(salt)
+	for n := len(password); n > 0; n -= 16 {
+		ctx.Write(altSum[:minInt(n, 16)])
+	}
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 == 1 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(password[:1])
+		}
+	}
+	sum := ctx.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		h := md5.New()
+		if i&1 == 1 {
+			h.Write(password)
+		} else {
+			h.Write(sum)
+		}
+		if i%3 != 0 {
+			h.Write(salt)
+		}
+		if i%7 != 0 {
+			h.Write(password)
+		}
+		if i&1 == 1 {
+			h.Write(sum)
+		} else {
+			h.Write(password)
+		}
+		sum = h.Sum(nil)
+	}
+
+	groups := [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}}
+	return magic + string(salt) + "$" + cryptEncode(sum, groups, 11)
+}
+
+// verifySHA512Crypt checks $6$[rounds=N$]salt$hash hashes.
+func verifySHA512Crypt(password, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	rounds := 5000
+	custom := false
+	if len(parts) == 5 && strings.HasPrefix(parts[2], "rounds=") {
+		n, err := strconv.Atoi(parts[2][len("rounds="):])
+		if err != nil || n > maxLegacyRounds {
+			return false, ErrMalformedHash
+		}
+		if n < 1000 {
+			n = 1000
+		}
+		rounds, custom = n, true
+		parts = append(parts[:2], parts[3:]...)
+	}
+	if len(parts) != 4 {
+		return false, ErrMalformedHash
+	}
+	return equal([]byte(sha512Crypt([]byte(password), []byte(parts[2]), rounds, custom)), []byte(encoded)), nil
+}
+
+// sha512Groups is byte order of SHA-512 crypt encoding.
+var sha512Groups = [][3]int{
+	{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4}, {47, 5, 26}, {6, 27, 48},
+	{28, 49, 7}, {50, 8, 29}, {9, 30, 51}, {31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13},
+	{56, 14, 35}, {15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19}, {62, 20, 41},
+}
+
+func sha512Crypt(password, salt []byte, rounds int, custom bool) string {
+	if len(salt) > 16 {
+		salt = salt[:16]
+	}
+	newHash := sha512.New
+	size := sha512.Size
+
+	alt := newHash()
+	alt.Write(password)
+	alt.Write(salt)
+	alt.Write(password)
+	altSum := alt.Sum(nil)
+
+	a := newHash()
+	a.Write(password)
+	a.Write(salt)
+	repeat(a, altSum, len(password))
+	for n := len(password); n > 0; n >>= 1 {
+		if n&1 == 1 {
+			a.Write(altSum)
+		} else {
+			a.Write(password)
+		}
+	}
+	sum := a.Sum(nil)
+
+	dp := newHash()
+	for range password {
+		dp.Write(password)
+	}
+	p := expand(dp.Sum(nil), len(password))
+
+	ds := newHash()
+	for i := 0; i < 16+int(sum[0]); i++ {
+		ds.Write(salt)
+	}
+	s := expand(ds.Sum(nil), len(salt))
+
+	for i := 0; i < rounds; i++ {
+		h := newHash()
+		if i&1 == 1 {
+			h.Write(p)
+		} else {
+			h.Write(sum[:size])
+		}
+		if i%3 != 0 {
+			h.Write(s)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i&1 == 1 {
+			h.Write(sum[:size])
+		} else {
+			h.Write(p)
+		}
+		sum = h.Sum(nil)
+	}
+
+	result := "$6$"
+	if custom {
+		result += "rounds=" + strconv.Itoa(rounds) + "$"
+	}
+	return result + string(salt) + "$" + cryptEncode(sum, sha512Groups, 63)
+}
+
+// repeat writes b to h up to n bytes.
+func repeat(h hash.Hash, b []byte, n int) {
+	for ; n > len(b); n -= len(b) {
+		h.Write(b)
+	}
+	h.Write(b[:n])
+}
+
+// expand repeats b to length n.
+func expand(b []byte, n int) []byte {
+	result := make([]byte, 0, n)
+	for len(result) < n {
+		result = append(result, b[:minInt(len(b), n-len(result))]...)
+	}
+	return result
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// verifyDjango checks pbkdf2_sha256$iterations$salt$hash hashes of Django.
+func verifyDjango(password, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 {
+		return false, ErrMalformedHash
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 || iterations > maxLegacyRounds {
+		return false, ErrMalformedHash
+	}
+	expected, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(expected) == 0 {
+		return false, ErrMalformedHash
+	}
+	h := sha256.New
+	if parts[0] == "pbkdf2_sha1" {
+		h = sha1.New
+	}
+	key := pbkdf2.Key([]byte(password), []byte(parts[2]), iterations, len(expected), h)
+	return equal(key, expected), nil
+}
+
+// verifySSHA checks LDAP {SSHA} hashes, base64 of SHA-1 digest followed by salt.
+func verifySSHA(password, encoded string) (bool, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded[len("{SSHA}"):])
+	if err != nil || len(data) <= sha1.Size {
+		return false, ErrMalformedHash
+	}
+	digest, salt := data[:sha1.Size], data[sha1.Size:]
+	h := sha1.New()
+	h.Write([]byte(password))
+	h.Write(salt)
+	return equal(h.Sum(nil), digest), nil
+}
+
+// verifySHA checks unsalted {SHA} hashes of Apache htpasswd and LDAP, base64 of SHA-1 digest.
+func verifySHA(password, encoded string) (bool, error) {
+	digest, err := base64.StdEncoding.DecodeString(encoded[len("{SHA}"):])
+	if err != nil || len(digest) != sha1.Size {
+		return false, ErrMalformedHash
+	}
+	sum := sha1.Sum([]byte(password))
+	return equal(sum[:], digest), nil
+}
@@ -0,0 +1,47 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegacyVerifiers(t *testing.T) {
+	hashes := map[string]string{
+		"$1$saltsalt$qjXMvbEw8oaL.CzflDtaK/":    "password",
+		"$apr1$saltsalt$yAAkm4libquA.ZWLHbSBq/": "password",
+		"$apr1$xyz$HXgo9gtz4gpj4JWTLYmjB0":      "secret",
+		"$6$saltsalt$qFmFH.bQmmtXzyBY0s9v7Oicd2z4XSIecDzlB5KiA2/jctKu9YterLp8wwnSq.qc.eoxqOmSuNp2xS0ktL3nh/":        "password",
+		"$6$rounds=1000$abc$MqEcPZUYRGGcOeq7PhMpfjfu/F0HrVEI0OlZBijWvO8mSG77iNUDP5MqFceKpJTBc8iITVtNyLiNTRNCxv6oh0": "secret",
+		"pbkdf2_sha256$1000$salt$qN+JnzxPIE2WfgrWPAkph8EAVeuwF7PZ0ordIY1Peq0=":                                      "secret",
+		"{SSHA}+RFhsab2AfzZ0VfEdyknXtUT06RhYmNk":                                                                    "secret",
+		"{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=":                                                                         "password",
+	}
+	for hash, pwd := range hashes {
+		ok, err := Verify(pwd, hash)
+		assert.Nil(t, err, hash)
+		assert.True(t, ok, hash)
+
+		ok, err = Verify(pwd+"x", hash)
+		assert.Nil(t, err, hash)
+		assert.False(t, ok, hash)
+
+		assert.True(t, testArgon2.NeedsRehash(hash))
+	}
+}
+
+func TestLegacyVerifiers_Malformed(t *testing.T) {
+	for _, hash := range []string{"$1$salt", "$6$rounds=x$salt$hash", "$6$rounds=999999999$salt$hash",
+		"pbkdf2_sha256$x$salt$hash", "pbkdf2_sha256$999999999$salt$c2FsdA==", "{SSHA}***", "$apr1$salt", "{SHA}c2hvcnQ="} {
+		_, err := Verify("secret", hash)
+		assert.Equal(t, ErrMalformedHash, err, hash)
+	}
+}
+
+func TestVerifyAndRehash_Legacy(t *testing.T) {
+	ok, newHash, err := VerifyAndRehash(testArgon2, "secret", "{SSHA}+RFhsab2AfzZ0VfEdyknXtUT06RhYmNk")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, _ = testArgon2.Verify("secret", newHash)
+	assert.True(t, ok)
+}
@@ -0,0 +1,147 @@
+// Package password hashes and verifies user passwords.
+//
+// Hashes are encoded as PHC strings, e.g. $argon2id$v=19$m=65536,t=3,p=2$salt$hash,
+// bcrypt hashes keep their standard $2a$ format.
+package password
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrUnknownFormat is returned when hash does not match any registered verifier.
+	ErrUnknownFormat = errors.New("password: unknown hash format")
+	// ErrMalformedHash is returned when hash cannot be decoded.
+	ErrMalformedHash = errors.New("password: malformed hash")
+)
+
+// Hasher hashes passwords and verifies hashes.
+type Hasher interface {
+	// Hash returns encoded hash of given password
+	Hash(password string) (string, error)
+	// Verify reports whether password matches encoded hash of any supported format
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether encoded hash was produced with other algorithm or parameters
+	NeedsRehash(encoded string) bool
+}
+
+// Verifier checks password against encoded hash of particular format.
+type Verifier func(password, encoded string) (bool, error)
+
+var (
+	verifiersLock sync.RWMutex
+	verifiers     = make(map[string]Verifier)
+)
+
+// RegisterVerifier adds verifier of hashes starting with given prefix.
+// Longest matching prefix wins.
+func RegisterVerifier(prefix string, v Verifier) {
+	verifiersLock.Lock()
+	defer verifiersLock.Unlock()
+	verifiers[prefix] = v
+}
+
+// Verify checks password against hash of any registered format.
+func Verify(password, encoded string) (bool, error) {
+	v := lookupVerifier(encoded)
+	if v == nil {
+		return false, ErrUnknownFormat
+	}
+	return v(password, encoded)
+}
+
+// IsKnownFormat reports whether hash has format of any registered verifier.
+func IsKnownFormat(encoded string) bool {
+	return lookupVerifier(encoded) != nil
+}
+
+func lookupVerifier(encoded string) Verifier {
+	verifiersLock.RLock()
+	defer verifiersLock.RUnlock()
+	prefixes := make([]string, 0, len(verifiers))
+	for prefix := range verifiers {
+		if strings.HasPrefix(encoded, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) == 0 {
+		return nil
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+	return verifiers[prefixes[0]]
+}
+
+// Default returns argon2id hasher with recommended parameters.
+func Default() Hasher {
+	return &Argon2id{}
+}
+
+// VerifyAndRehash verifies password and returns new hash if stored one needs upgrade.
+// Stores call it from ValidateCredentials to migrate hashes on successful login.
+func VerifyAndRehash(h Hasher, password, encoded string) (bool, string, error) {
+	ok, err := h.Verify(password, encoded)
+	if err != nil || !ok {
+		return false, "", err
+	}
+	if !h.NeedsRehash(encoded) {
+		return true, "", nil
+	}
+	hash, err := h.Hash(password)
+	if err != nil {
+		return true, "", err
+	}
+	return true, hash, nil
+}
+
+var b64 = base64.RawStdEncoding
+
+func equal(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// phc is decoded $id$v=19$k=v,k=v$salt$hash string.
+type phc struct {
+	id      string
+	version string
+	params  map[string]string
+	salt    []byte
+	hash    []byte
+}
+
+func parsePHC(encoded string) (*phc, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) < 5 || len(parts[0]) != 0 {
+		return nil, ErrMalformedHash
+	}
+	p := &phc{id: parts[1], params: make(map[string]string)}
+	i := 2
+	if strings.HasPrefix(parts[i], "v=") {
+		p.version = parts[i][2:]
+		i++
+	}
+	if len(parts) != i+3 {
+		return nil, ErrMalformedHash
+	}
+	for _, kv := range strings.Split(parts[i], ",") {
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) != 2 {
+			return nil, ErrMalformedHash
+		}
+		p.params[pair[0]] = pair[1]
+	}
+	var err error
+	if p.salt, err = b64.DecodeString(parts[i+1]); err != nil {
+		return nil, ErrMalformedHash
+	}
+	if p.hash, err = b64.DecodeString(parts[i+2]); err != nil {
+		return nil, ErrMalformedHash
+	}
+	return p, nil
+}
@@ -0,0 +1,141 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fast parameters keep tests quick
+var (
+	testArgon2 = &Argon2id{Time: 1, Memory: 1024, Threads: 1}
+	testScrypt = &Scrypt{LogN: 4}
+	testBcrypt = &Bcrypt{Cost: 4}
+)
+
+func TestHashers(t *testing.T) {
+	for _, h := range []Hasher{testArgon2, testScrypt, testBcrypt} {
+		hash, err := h.Hash("secret")
+		assert.Nil(t, err)
+		assert.NotContains(t, hash, "secret")
+
+		ok, err := h.Verify("secret", hash)
+		assert.Nil(t, err)
+		assert.True(t, ok, hash)
+
+		ok, err = h.Verify("wrong", hash)
+		assert.Nil(t, err)
+		assert.False(t, ok, hash)
+
+		assert.False(t, h.NeedsRehash(hash), hash)
+
+		other, _ := h.Hash("secret")
+		assert.NotEqual(t, hash, other, "salt must be random")
+	}
+}
+
+func TestArgon2id_Format(t *testing.T) {
+	hash, _ := Default().Hash("secret")
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=2$"), hash)
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash, _ := testBcrypt.Hash("secret")
+	assert.True(t, testArgon2.NeedsRehash(hash))
+	assert.True(t, (&Bcrypt{Cost: 5}).NeedsRehash(hash))
+
+	hash, _ = testArgon2.Hash("secret")
+	assert.True(t, (&Argon2id{Time: 2, Memory: 1024, Threads: 1}).NeedsRehash(hash))
+	assert.True(t, testScrypt.NeedsRehash(hash))
+
+	// any supported format verifies regardless of current algorithm
+	ok, err := testScrypt.Verify("secret", hash)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestVerifyAndRehash(t *testing.T) {
+	hash, _ := testBcrypt.Hash("secret")
+
+	ok, newHash, err := VerifyAndRehash(testArgon2, "secret", hash)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.True(t, strings.HasPrefix(newHash, argon2Prefix))
+
+	ok, newHash, err = VerifyAndRehash(testArgon2, "secret", newHash)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, newHash)
+
+	ok, newHash, _ = VerifyAndRehash(testArgon2, "wrong", hash)
+	assert.False(t, ok)
+	assert.Empty(t, newHash)
+}
+
+func TestVerify_Malformed(t *testing.T) {
+	_, err := Verify("secret", "plaintext")
+	assert.Equal(t, ErrUnknownFormat, err)
+	assert.False(t, IsKnownFormat("plaintext"))
+	assert.True(t, IsKnownFormat("$2y$05$hash"))
+	_, err = Verify("secret", "$argon2id$v=19$m=x$salt$hash")
+	assert.Equal(t, ErrMalformedHash, err)
+	_, err = Verify("secret", "$scrypt$ln=99,r=8,p=1$c2FsdA$aGFzaA")
+	assert.Equal(t, ErrMalformedHash, err)
+}
+
+func TestVerify_UnsafeParams(t *testing.T) {
+	// parameters that panic in key derivation or take too much memory or time
+	for _, params := range []string{
+		"$argon2id$v=19$m=65536,t=0,p=1$",
+		"$argon2id$v=19$m=65536,t=1,p=0$",
+		"$argon2id$v=19$m=0,t=1,p=1$",
+		"$argon2id$v=19$m=4294967295,t=1,p=1$",
+		"$argon2id$v=19$m=65536,t=4294967295,p=1$",
+		"$scrypt$ln=31,r=8,p=1$",
+		"$scrypt$ln=15,r=0,p=1$",
+		"$scrypt$ln=15,r=8,p=0$",
+		"$scrypt$ln=15,r=-1,p=1$",
+		"$scrypt$ln=4,r=1000000000,p=1$",
+		"$scrypt$ln=4,r=8,p=1000000$",
+	} {
+		assert.NotPanics(t, func() {
+			_, err := Verify("secret", params+"c2FsdHNhbHQ$aGFzaGhhc2g")
+			assert.Equal(t, ErrMalformedHash, err, params)
+		})
+	}
+}
+
+func TestPepper(t *testing.T) {
+	keys := map[string][]byte{"1": []byte("key-1"), "2": []byte("key-2")}
+	p1 := &Pepper{Hasher: testArgon2, Keys: keys, Current: "1"}
+	p2 := &Pepper{Hasher: testArgon2, Keys: keys, Current: "2"}
+
+	hash, err := p1.Hash("secret")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$pepper$k=1$argon2id$"), hash)
+
+	ok, _ := p1.Verify("secret", hash)
+	assert.True(t, ok)
+	ok, _ = p1.Verify("wrong", hash)
+	assert.False(t, ok)
+	assert.False(t, p1.NeedsRehash(hash))
+
+	// pepper is required to verify
+	ok, _ = testArgon2.Verify("secret", hash[len("$pepper$k=1"):])
+	assert.False(t, ok)
+
+	// rotated key still verifies and asks for rehash
+	ok, _ = p2.Verify("secret", hash)
+	assert.True(t, ok)
+	assert.True(t, p2.NeedsRehash(hash))
+
+	// hashes created before pepper
+	plain, _ := testArgon2.Hash("secret")
+	ok, _ = p2.Verify("secret", plain)
+	assert.True(t, ok)
+	assert.True(t, p2.NeedsRehash(plain))
+
+	_, err = (&Pepper{Hasher: testArgon2, Keys: map[string][]byte{}, Current: "1"}).Verify("secret", hash)
+	assert.Equal(t, ErrUnknownPepper, err)
+}
@@ -0,0 +1,70 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"strings"
+)
+
+const pepperPrefix = "$pepper$k="
+
+// ErrUnknownPepper is returned when hash was peppered with key missing from Pepper.Keys.
+var ErrUnknownPepper = errors.New("password: unknown pepper key")
+
+// Pepper mixes server-side secret into passwords before hashing.
+// Hashes are prefixed with key ID, e.g. $pepper$k=2$argon2id$..., so keys can be rotated:
+// hashes with old keys still verify and report NeedsRehash.
+type Pepper struct {
+	Hasher  Hasher
+	Keys    map[string][]byte
+	Current string
+}
+
+func (p *Pepper) Hash(password string) (string, error) {
+	key, ok := p.Keys[p.Current]
+	if !ok {
+		return "", ErrUnknownPepper
+	}
+	hash, err := p.Hasher.Hash(pepper(key, password))
+	if err != nil {
+		return "", err
+	}
+	return pepperPrefix + p.Current + hash, nil
+}
+
+// Verify checks peppered hashes and plain ones created before pepper was introduced.
+func (p *Pepper) Verify(password, encoded string) (bool, error) {
+	id, inner, peppered := splitPepper(encoded)
+	if !peppered {
+		return p.Hasher.Verify(password, encoded)
+	}
+	key, ok := p.Keys[id]
+	if !ok {
+		return false, ErrUnknownPepper
+	}
+	return p.Hasher.Verify(pepper(key, password), inner)
+}
+
+func (p *Pepper) NeedsRehash(encoded string) bool {
+	id, inner, peppered := splitPepper(encoded)
+	return !peppered || id != p.Current || p.Hasher.NeedsRehash(inner)
+}
+
+func splitPepper(encoded string) (string, string, bool) {
+	if !strings.HasPrefix(encoded, pepperPrefix) {
+		return "", "", false
+	}
+	rest := encoded[len(pepperPrefix):]
+	i := strings.Index(rest, "$")
+	if i <= 0 {
+		return "", "", false
+	}
+	return rest[:i], rest[i:], true
+}
+
+func pepper(key []byte, password string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return b64.EncodeToString(mac.Sum(nil))
+}
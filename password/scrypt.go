@@ -0,0 +1,109 @@
+package password
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const scryptPrefix = "$scrypt$"
+
+// bounds of scrypt parameters accepted from stored hashes, so crafted hash cannot exhaust memory or CPU
+const (
+	maxScryptMemory = 1 << 30 // bytes, scrypt needs 128*r*N
+	maxScryptP      = 16
+)
+
+// Scrypt hasher, LogN is log2 of CPU/memory cost, zero fields take recommended defaults.
+type Scrypt struct {
+	LogN    uint8
+	R       int
+	P       int
+	KeyLen  int
+	SaltLen int
+}
+
+func init() {
+	RegisterVerifier(scryptPrefix, verifyScrypt)
+}
+
+func (s *Scrypt) params() Scrypt {
+	p := *s
+	if p.LogN == 0 {
+		p.LogN = 15
+	}
+	if p.R == 0 {
+		p.R = 8
+	}
+	if p.P == 0 {
+		p.P = 1
+	}
+	if p.KeyLen == 0 {
+		p.KeyLen = 32
+	}
+	if p.SaltLen == 0 {
+		p.SaltLen = 16
+	}
+	return p
+}
+
+func (s *Scrypt) Hash(password string) (string, error) {
+	p := s.params()
+	salt := make([]byte, p.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := scrypt.Key([]byte(password), salt, 1<<p.LogN, p.R, p.P, p.KeyLen)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%sln=%d,r=%d,p=%d$%s$%s", scryptPrefix, p.LogN, p.R, p.P,
+		b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (s *Scrypt) Verify(password, encoded string) (bool, error) {
+	return Verify(password, encoded)
+}
+
+func (s *Scrypt) NeedsRehash(encoded string) bool {
+	h, p, err := decodeScrypt(encoded)
+	if err != nil {
+		return true
+	}
+	want := s.params()
+	return p.LogN != want.LogN || p.R != want.R || p.P != want.P ||
+		len(h.hash) != want.KeyLen || len(h.salt) != want.SaltLen
+}
+
+func decodeScrypt(encoded string) (*phc, Scrypt, error) {
+	var p Scrypt
+	h, err := parsePHC(encoded)
+	if err != nil || h.id != "scrypt" {
+		return nil, p, ErrMalformedHash
+	}
+	ln, err1 := strconv.ParseUint(h.params["ln"], 10, 8)
+	r, err2 := strconv.Atoi(h.params["r"])
+	par, err3 := strconv.Atoi(h.params["p"])
+	if err1 != nil || err2 != nil || err3 != nil || ln == 0 || ln > 31 || len(h.hash) == 0 {
+		return nil, p, ErrMalformedHash
+	}
+	if r <= 0 || par <= 0 || par > maxScryptP || uint64(r) > maxScryptMemory/128>>ln {
+		return nil, p, ErrMalformedHash
+	}
+	p.LogN, p.R, p.P = uint8(ln), r, par
+	return h, p, nil
+}
+
+func verifyScrypt(password, encoded string) (bool, error) {
+	h, p, err := decodeScrypt(encoded)
+	if err != nil {
+		return false, err
+	}
+	key, err := scrypt.Key([]byte(password), h.salt, 1<<p.LogN, p.R, p.P, len(h.hash))
+	if err != nil {
+		return false, ErrMalformedHash
+	}
+	return equal(key, h.hash), nil
+}
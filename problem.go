@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"fmt"
+	"html/template"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrorFormat selects JSON representation of errors.
+type ErrorFormat int
+
+const (
+	// ErrorFormatLegacy renders error_code, error_message and status fields
+	ErrorFormatLegacy ErrorFormat = iota
+	// ErrorFormatProblem renders RFC 7807 application/problem+json
+	ErrorFormatProblem
+)
+
+const (
+	contentProblemJSON = "application/problem+json"
+	contentHTML        = "text/html"
+	contentText        = "text/plain"
+)
+
+// errorOffers are media types of error responses in order of preference.
+var errorOffers = []string{contentJSON, contentProblemJSON, contentHTML, contentText}
+
+type legacyErrorBody struct {
+	Code    string `json:"error_code,omitempty"`
+	Message string `json:"error_message,omitempty"`
+	Status  int    `json:"status"`
+	Cause   string `json:"cause,omitempty"`
+}
+
+// Problem is RFC 7807 problem details object with code extension member.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Status}} {{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Detail}}<p>{{.Detail}}</p>{{end}}
+<p><small>{{.Code}}</small></p>
+</body>
+</html>
+`))
+
+// MakeProblem returns problem details for given error.
+func MakeProblem(config *Config, r *http.Request, err *Error) *Problem {
+	p := &Problem{
+		Type:   "about:blank",
+		Title:  err.Message,
+		Status: err.Status,
+		Code:   err.Code,
+	}
+	if len(config.ProblemTypeBase) > 0 {
+		p.Type = config.ProblemTypeBase + strings.ToLower(err.Code)
+	}
+	if r != nil {
+		// path only, query string may contain token
+		p.Instance = r.URL.Path
+	}
+	p.Detail = errorCause(config, err)
+	return p
+}
+
+func errorCause(config *Config, err *Error) string {
+	if !config.ExposeErrorCause || err.Cause == nil {
+		return ""
+	}
+	return Redact(err.Cause.Error())
+}
+
+// renderError writes error body in format negotiated by Accept header.
+func renderError(config *Config, w http.ResponseWriter, r *http.Request, err *Error) {
+	w.Header().Add("Vary", "Accept")
+	accept := ""
+	if r != nil {
+		accept = r.Header.Get("Accept")
+	}
+
+	switch negotiate(accept, errorOffers) {
+	case contentHTML:
+		w.Header().Set("Content-Type", contentHTML+"; charset=utf-8")
+		w.WriteHeader(err.Status)
+		errorPage.Execute(w, MakeProblem(config, r, err))
+	case contentText:
+		w.Header().Set("Content-Type", contentText+"; charset=utf-8")
+		w.WriteHeader(err.Status)
+		fmt.Fprintf(w, "%d %s: %s\n", err.Status, err.Code, err.Message)
+		if cause := errorCause(config, err); len(cause) > 0 {
+			fmt.Fprintln(w, cause)
+		}
+	case contentProblemJSON:
+		writeProblem(config, w, r, err)
+	default:
+		if config.ErrorFormat == ErrorFormatProblem {
+			writeProblem(config, w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", contentJSON)
+		w.WriteHeader(err.Status)
+		SendJSON(w, &legacyErrorBody{
+			Code:    err.Code,
+			Message: err.Message,
+			Status:  err.Status,
+			Cause:   errorCause(config, err),
+		})
+	}
+}
+
+func writeProblem(config *Config, w http.ResponseWriter, r *http.Request, err *Error) {
+	w.Header().Set("Content-Type", contentProblemJSON)
+	w.WriteHeader(err.Status)
+	SendJSON(w, MakeProblem(config, r, err))
+}
+
+// negotiate returns offer best matching Accept header, first offer if nothing matches.
+func negotiate(accept string, offers []string) string {
+	if len(strings.TrimSpace(accept)) == 0 {
+		return offers[0]
+	}
+
+	best := offers[0]
+	bestQ := -1.0
+	bestSpecificity := -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if v, err := strconv.ParseFloat(s, 64); err == nil {
+				q = v
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		for _, offer := range offers {
+			specificity := matchMediaType(mediaType, offer)
+			if specificity < 0 {
+				continue
+			}
+			if q > bestQ || q == bestQ && specificity > bestSpecificity {
+				best, bestQ, bestSpecificity = offer, q, specificity
+			}
+			// first matching offer wins for wildcards
+			break
+		}
+	}
+	return best
+}
+
+// matchMediaType returns specificity of pattern matching media type or -1.
+func matchMediaType(pattern, mediaType string) int {
+	switch {
+	case pattern == mediaType:
+		return 2
+	case pattern == "*/*":
+		return 0
+	case strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")):
+		return 1
+	default:
+		return -1
+	}
+}
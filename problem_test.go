@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	assert.Equal(t, contentJSON, negotiate("", errorOffers))
+	assert.Equal(t, contentJSON, negotiate("*/*", errorOffers))
+	assert.Equal(t, contentHTML, negotiate("text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", errorOffers))
+	assert.Equal(t, contentText, negotiate("text/plain", errorOffers))
+	assert.Equal(t, contentProblemJSON, negotiate("application/problem+json, application/json;q=0.5", errorOffers))
+	assert.Equal(t, contentJSON, negotiate("image/png", errorOffers))
+}
+
+func TestError_LegacyFormat(t *testing.T) {
+	config := makeTestConfig()
+	c := makectx(t, config, middlewareServer(config))
+	obj := c.expect.GET("/data").
+		WithHeader(authorizationHeader, "Bearer invalid").
+		Expect().
+		Status(http.StatusUnauthorized).
+		ContentType(contentJSON).
+		JSON().Object()
+	obj.Value("error_code").String().Equal(ErrInvalidToken.Code)
+	obj.Value("status").Number().Equal(http.StatusUnauthorized)
+	obj.NotContainsKey("cause")
+}
+
+func TestError_ProblemFormat(t *testing.T) {
+	config := makeTestConfig()
+	config.ErrorFormat = ErrorFormatProblem
+	config.ProblemTypeBase = "https://errors.test.net/"
+	config.ExposeErrorCause = true
+	c := makectx(t, config, middlewareServer(config))
+	obj := c.expect.GET("/data").
+		WithHeader(authorizationHeader, "Bearer invalid").
+		Expect().
+		Status(http.StatusUnauthorized).
+		ContentType(contentProblemJSON).
+		JSON().Object()
+	obj.Value("type").String().Equal("https://errors.test.net/auth-invalid-token")
+	obj.Value("title").String().Equal(ErrInvalidToken.Message)
+	obj.Value("status").Number().Equal(http.StatusUnauthorized)
+	obj.Value("instance").String().Equal("/data")
+	obj.Value("code").String().Equal(ErrInvalidToken.Code)
+	obj.Value("detail").String().NotEmpty()
+}
+
+func TestError_HTMLAndText(t *testing.T) {
+	config := makeTestConfig()
+	c := makectx(t, config, middlewareServer(config))
+
+	c.expect.GET("/data").
+		WithHeader("Accept", "text/html").
+		Expect().
+		Status(http.StatusUnauthorized).
+		ContentType(contentHTML).
+		Body().Contains("<h1>" + ErrBadAuthorizationHeader.Message + "</h1>")
+
+	c.expect.GET("/data").
+		WithHeader("Accept", "text/plain").
+		Expect().
+		Status(http.StatusUnauthorized).
+		ContentType(contentText).
+		Body().Equal("401 AUTH-BAD-AUTHORIZATION-HEADER: Invalid authorization header\n")
+}
+
+func TestError_CauseHiddenByDefault(t *testing.T) {
+	config := makeTestConfig()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	SendRequestError(config, w, r, ErrBadState.WithCause(errors.New("db password=secret")))
+	assert.NotContains(t, w.Body.String(), "secret")
+	assert.NotContains(t, w.Body.String(), "cause")
+
+	config.ExposeErrorCause = true
+	w = httptest.NewRecorder()
+	SendRequestError(config, w, r, ErrBadState.WithCause(errors.New("db password=secret")))
+	assert.Contains(t, w.Body.String(), "password=[REDACTED]")
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit defines token bucket with Burst capacity refilled by one token Every interval.
+// Zero Burst disables the limit.
+type RateLimit struct {
+	Burst int
+	Every time.Duration
+}
+
+// RateLimitStore keeps token buckets. Implementations may share counters between instances.
+type RateLimitStore interface {
+	// Take takes one token from bucket with given key.
+	// It returns false and duration to wait if bucket is empty.
+	Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error)
+	// Refund returns token taken for attempt that turned out successful.
+	Refund(ctx context.Context, key string, limit RateLimit) error
+}
+
+// RateLimitPolicy limits failed login attempts by client IP, by username and globally.
+// Successful attempts do not count, so clients using basic auth on every request are not limited.
+type RateLimitPolicy struct {
+	Store   RateLimitStore
+	PerIP   RateLimit
+	PerUser RateLimit
+	Global  RateLimit
+}
+
+type rateLimitToken struct {
+	key   string
+	limit RateLimit
+}
+
+// checkRateLimit takes tokens for login attempt of given user from request.
+// Global token is taken only if per-IP and per-user buckets allow the attempt,
+// so client over its own limit cannot drain global bucket for everybody.
+// Taken tokens should be refunded if attempt succeeds.
+func checkRateLimit(config *Config, r *http.Request, username string) ([]rateLimitToken, *Error) {
+	policy := config.RateLimit
+	if policy == nil || policy.Store == nil {
+		return nil, nil
+	}
+
+	buckets := []rateLimitToken{
+		{"ip:" + getClientIP(config, r), policy.PerIP},
+		{"user:" + strings.ToLower(username), policy.PerUser},
+		{"global", policy.Global},
+	}
+
+	var taken []rateLimitToken
+	for _, b := range buckets {
+		if b.limit.Burst <= 0 {
+			continue
+		}
+		ok, wait, err := policy.Store.Take(r.Context(), b.key, b.limit)
+		if err != nil {
+			// do not lock everybody out if store is unavailable
+			config.Log(r, LogStoreFailure, LevelError, "rate limit store failed", Fields{"error": err})
+			continue
+		}
+		if !ok {
+			return taken, ErrTooManyRequests.WithRetryAfter(wait)
+		}
+		taken = append(taken, b)
+	}
+
+	return taken, nil
+}
+
+// refundRateLimit returns tokens taken for successful attempt.
+func refundRateLimit(config *Config, r *http.Request, tokens []rateLimitToken) {
+	for _, t := range tokens {
+		if err := config.RateLimit.Store.Refund(r.Context(), t.key, t.limit); err != nil {
+			config.Log(r, LogStoreFailure, LevelError, "rate limit store failed", Fields{"error": err})
+		}
+	}
+}
+
+// NewMemoryRateLimitStore creates in-memory RateLimitStore for single instance deployments.
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  RateLimit
+}
+
+// refill adds tokens accumulated since last update.
+func (b *bucket) refill(t time.Time) {
+	if b.limit.Every > 0 {
+		b.tokens += float64(t.Sub(b.last)) / float64(b.limit.Every)
+	}
+	b.tokens = math.Min(b.tokens, float64(b.limit.Burst))
+	b.last = t
+}
+
+type memoryRateLimitStore struct {
+	sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func (s *memoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	t := now()
+	s.sweep(t)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens: float64(limit.Burst),
+			last:   t,
+		}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	b.refill(t)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+
+	if limit.Every <= 0 {
+		return false, 0, nil
+	}
+	wait := time.Duration((1 - b.tokens) * float64(limit.Every))
+	return false, wait, nil
+}
+
+func (s *memoryRateLimitStore) Refund(ctx context.Context, key string, limit RateLimit) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if b, ok := s.buckets[key]; ok {
+		b.limit = limit
+		b.refill(now())
+		b.tokens = math.Min(b.tokens+1, float64(limit.Burst))
+	}
+	return nil
+}
+
+// sweep drops full buckets once a minute to bound memory usage.
+func (s *memoryRateLimitStore) sweep(t time.Time) {
+	if t.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = t
+	for key, b := range s.buckets {
+		b.refill(t)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
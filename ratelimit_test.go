@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Burst: 2, Every: time.Minute}
+
+	ok, _, err := store.Take(ctx, "key", limit)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, _, _ = store.Take(ctx, "key", limit)
+	assert.True(t, ok)
+
+	ok, wait, _ := store.Take(ctx, "key", limit)
+	assert.False(t, ok)
+	assert.True(t, wait > 0 && wait <= time.Minute)
+
+	ok, _, _ = store.Take(ctx, "other", limit)
+	assert.True(t, ok)
+
+	assert.Nil(t, store.Refund(ctx, "key", limit))
+	ok, _, _ = store.Take(ctx, "key", limit)
+	assert.True(t, ok)
+	ok, _, _ = store.Take(ctx, "key", limit)
+	assert.False(t, ok)
+}
+
+func TestLoginHandler_RateLimitPerUser(t *testing.T) {
+	config := makeTestConfig()
+	config.RateLimit = &RateLimitPolicy{
+		Store:   NewMemoryRateLimitStore(),
+		PerUser: RateLimit{Burst: 2, Every: time.Minute},
+	}
+	handler := LoginHandler(config)
+	c := makectx(t, config, httptest.NewServer(handler))
+
+	for i := 0; i < 2; i++ {
+		c.expect.POST("/").WithJSON(&Credentials{UserName: "bob", Password: "1"}).
+			Expect().
+			Status(http.StatusUnauthorized)
+	}
+
+	c.expect.POST("/").WithJSON(&Credentials{UserName: "bob", Password: "b0b"}).
+		Expect().
+		Status(http.StatusTooManyRequests).
+		Header("Retry-After").
+		NotEmpty()
+
+	c.expect.POST("/").WithJSON(&Credentials{UserName: "rob", Password: "r0b"}).
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestBasicAuth_RateLimitPerIP(t *testing.T) {
+	config := makeTestConfig()
+	config.RateLimit = &RateLimitPolicy{
+		Store: NewMemoryRateLimitStore(),
+		PerIP: RateLimit{Burst: 1, Every: time.Minute},
+	}
+	c := makectx(t, config, middlewareServer(config))
+	// successful requests are not limited
+	for i := 0; i < 3; i++ {
+		c.expect.GET("/data").WithBasicAuth("bob", "b0b").Expect().Status(http.StatusOK)
+	}
+	c.expect.GET("/data").WithBasicAuth("rob", "1").Expect().Status(http.StatusUnauthorized)
+	c.expect.GET("/data").WithBasicAuth("rob", "r0b").Expect().Status(http.StatusTooManyRequests)
+}
+
+func TestLoginHandler_RateLimitGlobal(t *testing.T) {
+	config := makeTestConfig()
+	config.RateLimit = &RateLimitPolicy{
+		Store:   NewMemoryRateLimitStore(),
+		PerUser: RateLimit{Burst: 1, Every: time.Minute},
+		Global:  RateLimit{Burst: 2, Every: time.Minute},
+	}
+	c := makectx(t, config, httptest.NewServer(LoginHandler(config)))
+
+	c.expect.POST("/").WithJSON(&Credentials{UserName: "bob", Password: "1"}).
+		Expect().
+		Status(http.StatusUnauthorized)
+	// attempts rejected by per-user limit do not drain global bucket
+	for i := 0; i < 5; i++ {
+		c.expect.POST("/").WithJSON(&Credentials{UserName: "bob", Password: "1"}).
+			Expect().
+			Status(http.StatusTooManyRequests)
+	}
+	c.expect.POST("/").WithJSON(&Credentials{UserName: "rob", Password: "1"}).
+		Expect().
+		Status(http.StatusUnauthorized)
+	c.expect.POST("/").WithJSON(&Credentials{UserName: "joe", Password: "j0e"}).
+		Expect().
+		Status(http.StatusTooManyRequests)
+}
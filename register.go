@@ -19,33 +19,32 @@ func RegisterHandlerFunc(config *Config) http.HandlerFunc {
 		in := &struct {
 			Name  string `json:"name"`
 			Email string `json:"email"`
-			Role  string `json:"role"`
 		}{}
 
 		decoder := json.NewDecoder(r.Body)
 		err := decoder.Decode(in)
 		if err != nil {
-			SendError(w, ErrBadState.WithCause(err))
+			SendRequestError(config, w, r, ErrBadState.WithCause(err))
 			return
 		}
 		ctx := r.Context()
 		userStore := config.UserStoreEx
 		user, err := userStore.FindUserByEmail(ctx, in.Email)
 		if user != nil {
-			SendError(w, ErrBadState.WithCause(fmt.Errorf("such user already exists")))
+			SendRequestError(config, w, r, ErrBadState.WithCause(fmt.Errorf("such user already exists")))
 			return
 		}
 
 		account := UserData{
 			Name:  in.Name,
 			Email: in.Email,
-			Role:  in.Role,
 		}
 		user, err = userStore.CreateUser(ctx, account)
 		if err != nil {
-			SendError(w, ErrBadState.WithCause(err))
+			SendRequestError(config, w, r, ErrBadState.WithCause(err))
 			return
 		}
+		emitUserEvent(config, r, EventRegistration, user)
 
 		WriteLoginResponse(w, r, config, user)
 	}
@@ -0,0 +1,62 @@
+package sqlstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+// cipherBox encrypts OAuth tokens at rest with AES-GCM.
+type cipherBox struct {
+	aead cipher.AEAD
+}
+
+func newCipherBox(key []byte) (*cipherBox, error) {
+	if len(key) == 0 {
+		return nil, nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &cipherBox{aead: aead}, nil
+}
+
+// seal returns base64 of nonce and ciphertext, tokens are not stored without key.
+func (b *cipherBox) seal(plaintext string) (string, error) {
+	if b == nil || len(plaintext) == 0 {
+		return "", nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	data := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (b *cipherBox) open(encoded string) (string, error) {
+	if b == nil || len(encoded) == 0 {
+		return "", nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	size := b.aead.NonceSize()
+	if len(data) < size {
+		return "", errors.New("sqlstore: encrypted token is too short")
+	}
+	plaintext, err := b.aead.Open(nil, data[:size], data[size:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
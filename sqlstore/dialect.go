@@ -0,0 +1,71 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// Dialect describes differences of SQL databases.
+type Dialect interface {
+	Name() string
+	// Rebind replaces '?' placeholders of query with dialect specific ones
+	Rebind(query string) string
+	// LockMigrations serializes Migrate calls of concurrent instances until tx ends,
+	// auth_migrations table exists when it returns.
+	LockMigrations(ctx context.Context, tx *sql.Tx) error
+}
+
+var (
+	// SQLite dialect, e.g. for github.com/mattn/go-sqlite3 driver
+	SQLite Dialect = sqliteDialect{}
+	// PostgreSQL dialect, e.g. for github.com/lib/pq or github.com/jackc/pgx drivers
+	PostgreSQL Dialect = postgresDialect{}
+)
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (sqliteDialect) Rebind(query string) string {
+	return query
+}
+
+// LockMigrations writes to migrations table first, so transaction holds SQLite write lock.
+// Migrate creates the table before transaction.
+func (sqliteDialect) LockMigrations(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM auth_migrations WHERE version < 0`)
+	return err
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+// LockMigrations takes transaction-level advisory lock.
+func (postgresDialect) LockMigrations(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('auth_migrations'))`); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, migrationsTable)
+	return err
+}
+
+func (postgresDialect) Rebind(query string) string {
+	var sb strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,81 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migrations are applied in order, never edit applied ones, append new instead.
+var migrations = []string{
+	`CREATE TABLE auth_users (
+		id VARCHAR(64) PRIMARY KEY,
+		name VARCHAR(255) NOT NULL UNIQUE,
+		email VARCHAR(255) UNIQUE,
+		password_hash TEXT NOT NULL DEFAULT '',
+		first_name VARCHAR(255) NOT NULL DEFAULT '',
+		last_name VARCHAR(255) NOT NULL DEFAULT '',
+		nick_name VARCHAR(255) NOT NULL DEFAULT '',
+		avatar_url TEXT NOT NULL DEFAULT '',
+		location VARCHAR(255) NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE TABLE auth_roles (
+		user_id VARCHAR(64) NOT NULL REFERENCES auth_users(id) ON DELETE CASCADE,
+		role VARCHAR(64) NOT NULL,
+		PRIMARY KEY (user_id, role)
+	)`,
+	`CREATE TABLE auth_accounts (
+		provider VARCHAR(64) NOT NULL,
+		provider_user_id VARCHAR(255) NOT NULL,
+		user_id VARCHAR(64) NOT NULL REFERENCES auth_users(id) ON DELETE CASCADE,
+		access_token TEXT NOT NULL DEFAULT '',
+		access_token_secret TEXT NOT NULL DEFAULT '',
+		refresh_token TEXT NOT NULL DEFAULT '',
+		expires_at TIMESTAMP NULL,
+		updated_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (provider, provider_user_id)
+	)`,
+	`CREATE INDEX auth_accounts_user_id ON auth_accounts (user_id)`,
+}
+
+const migrationsTable = `CREATE TABLE IF NOT EXISTS auth_migrations (
+	version INTEGER PRIMARY KEY,
+	applied_at TIMESTAMP NOT NULL
+)`
+
+// Migrate creates or upgrades database schema.
+// Pending migrations are applied in single transaction under Dialect.LockMigrations,
+// so instances starting together do not race on the schema.
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, ok := s.dialect.(sqliteDialect); ok {
+		// SQLite cannot wait to upgrade read lock taken by CREATE TABLE IF NOT EXISTS in transaction,
+		// so table is created before and transaction starts with write that waits for busy timeout
+		if _, err := s.db.ExecContext(ctx, migrationsTable); err != nil {
+			return fmt.Errorf("sqlstore: cannot create migrations table: %w", err)
+		}
+	}
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		if err := s.dialect.LockMigrations(ctx, tx); err != nil {
+			return fmt.Errorf("sqlstore: cannot lock migrations: %w", err)
+		}
+
+		var version int
+		err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM auth_migrations`).Scan(&version)
+		if err != nil {
+			return err
+		}
+
+		for i := version; i < len(migrations); i++ {
+			if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+				return fmt.Errorf("sqlstore: migration %d failed: %w", i+1, err)
+			}
+			_, err := tx.ExecContext(ctx, s.dialect.Rebind(`INSERT INTO auth_migrations (version, applied_at) VALUES (?, ?)`), i+1, s.now())
+			if err != nil {
+				return fmt.Errorf("sqlstore: migration %d failed: %w", i+1, err)
+			}
+		}
+		return nil
+	})
+}
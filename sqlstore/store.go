@@ -0,0 +1,492 @@
+// Package sqlstore implements auth.UserStore and auth.UserStoreEx over database/sql.
+package sqlstore
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocontrib/auth"
+	"github.com/gocontrib/auth/password"
+)
+
+const defaultAdminRole = "admin"
+
+var (
+	// ErrNotFound is returned when user does not exist.
+	ErrNotFound = errors.New("sqlstore: user not found")
+	// ErrInvalidCredentials is returned when username or password does not match.
+	ErrInvalidCredentials = errors.New("sqlstore: invalid credentials")
+)
+
+// Options of SQL store.
+type Options struct {
+	// Dialect of the database, SQLite by default
+	Dialect Dialect
+	// EncryptionKey is AES key (16, 24 or 32 bytes) to encrypt OAuth tokens, tokens are not stored if it is empty
+	EncryptionKey []byte
+	// Hasher hashes passwords, argon2id by default.
+	// Hashes of other algorithms or parameters are upgraded on successful login, see UpgradePassword.
+	Hasher password.Hasher
+	// AdminRole is role of admin users, 'admin' by default
+	AdminRole string
+}
+
+// Store is SQL-backed user store.
+type Store struct {
+	db        *sql.DB
+	dialect   Dialect
+	box       *cipherBox
+	hasher    password.Hasher
+	adminRole string
+	now       func() time.Time
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+// New creates store over given database, call Migrate to create schema.
+func New(db *sql.DB, options Options) (*Store, error) {
+	box, err := newCipherBox(options.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{
+		db:        db,
+		dialect:   options.Dialect,
+		box:       box,
+		hasher:    options.Hasher,
+		adminRole: options.AdminRole,
+		now: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
+	if s.dialect == nil {
+		s.dialect = SQLite
+	}
+	if s.hasher == nil {
+		s.hasher = password.Default()
+	}
+	if len(s.adminRole) == 0 {
+		s.adminRole = defaultAdminRole
+	}
+	return s, nil
+}
+
+// User stored in database.
+type User struct {
+	ID        string
+	Name      string
+	Email     string
+	FirstName string
+	LastName  string
+	NickName  string
+	AvatarURL string
+	Location  string
+	Roles     []string
+	admin     bool
+}
+
+func (u *User) GetID() string {
+	return u.ID
+}
+
+func (u *User) GetName() string {
+	return u.Name
+}
+
+func (u *User) GetEmail() string {
+	return u.Email
+}
+
+func (u *User) IsAdmin() bool {
+	return u.admin
+}
+
+func (u *User) GetClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"roles": u.Roles,
+	}
+}
+
+// Account is OAuth account linked to user.
+type Account struct {
+	Provider          string
+	ProviderUserID    string
+	UserID            string
+	AccessToken       string
+	AccessTokenSecret string
+	RefreshToken      string
+	ExpiresAt         time.Time
+}
+
+const userColumns = `id, name, COALESCE(email, ''), password_hash, first_name, last_name, nick_name, avatar_url, location`
+
+// ValidateCredentials finds user by email if username contains '@' or by name otherwise,
+// so user name can never shadow email of another user.
+func (s *Store) ValidateCredentials(ctx context.Context, username, pwd string) (auth.User, error) {
+	where, key := `name = ?`, username
+	if strings.Contains(username, "@") {
+		where, key = `email = ?`, strings.ToLower(username)
+	}
+	user, hash, err := s.findUser(ctx, where, key)
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+	if len(hash) == 0 {
+		// spend the same time as for existing user to not reveal whether user exists
+		s.hasher.Verify(pwd, s.dummy())
+		return nil, ErrInvalidCredentials
+	}
+	ok, err := s.hasher.Verify(pwd, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// dummy returns hash verified for unknown users.
+func (s *Store) dummy() string {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = s.hasher.Hash("dummy password")
+	})
+	return s.dummyHash
+}
+
+// UpgradePassword re-hashes verified password if stored hash is legacy or has outdated parameters.
+func (s *Store) UpgradePassword(ctx context.Context, user auth.User, pwd string) error {
+	var hash string
+	err := s.db.QueryRowContext(ctx, s.dialect.Rebind(`SELECT password_hash FROM auth_users WHERE id = ?`), user.GetID()).Scan(&hash)
+	if err != nil {
+		return err
+	}
+	if len(hash) == 0 || !s.hasher.NeedsRehash(hash) {
+		return nil
+	}
+	newHash, err := s.hasher.Hash(pwd)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.Rebind(`UPDATE auth_users SET password_hash = ? WHERE id = ? AND password_hash = ?`), newHash, user.GetID(), hash)
+	return err
+}
+
+func (s *Store) FindUserByID(ctx context.Context, userID string) (auth.User, error) {
+	user, _, err := s.findUser(ctx, `id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *Store) FindUserByEmail(ctx context.Context, email string) (auth.User, error) {
+	user, _, err := s.findUser(ctx, `email = ?`, strings.ToLower(email))
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// FindUserByAccount returns user linked with given OAuth account.
+func (s *Store) FindUserByAccount(ctx context.Context, provider, providerUserID string) (auth.User, error) {
+	user, _, err := s.findUser(ctx, `id = (SELECT user_id FROM auth_accounts WHERE provider = ? AND provider_user_id = ?)`, provider, providerUserID)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *Store) findUser(ctx context.Context, where string, args ...interface{}) (*User, string, error) {
+	query := s.dialect.Rebind(`SELECT ` + userColumns + ` FROM auth_users WHERE ` + where)
+	user := &User{}
+	var hash string
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID, &user.Name, &user.Email, &hash,
+		&user.FirstName, &user.LastName, &user.NickName, &user.AvatarURL, &user.Location,
+	)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	user.Roles, err = s.roles(ctx, s.db, user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, role := range user.Roles {
+		if role == s.adminRole {
+			user.admin = true
+		}
+	}
+	return user, hash, nil
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (s *Store) roles(ctx context.Context, q queryer, userID string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, s.dialect.Rebind(`SELECT role FROM auth_roles WHERE user_id = ? ORDER BY role`), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// CreateUser creates user from given data and links OAuth account if data has provider.
+// Name is derived from display name, nickname or email and gets numeric suffix if it is taken,
+// so different people with the same display name can sign up.
+// Role of data is ignored, data may come from self-registration; grant roles with AddRole.
+func (s *Store) CreateUser(ctx context.Context, data auth.UserData) (auth.User, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	var hash string
+	if len(data.Password) > 0 {
+		hash, err = s.hasher.Hash(data.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	name := firstNonEmpty(data.Name, data.NickName, data.Email)
+	email := sql.NullString{String: strings.ToLower(data.Email), Valid: len(data.Email) > 0}
+	t := s.now()
+
+	err = s.tx(ctx, func(tx *sql.Tx) error {
+		name, err := s.uniqueName(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, s.dialect.Rebind(`INSERT INTO auth_users
+			(id, name, email, password_hash, first_name, last_name, nick_name, avatar_url, location, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			id, name, email, hash, data.FirstName, data.LastName, data.NickName, data.AvatarURL, data.Location, t, t)
+		if err != nil {
+			return err
+		}
+		if len(data.Provider) > 0 {
+			return s.linkAccount(ctx, tx, id, data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.FindUserByID(ctx, id)
+}
+
+// uniqueName returns name or name with numeric suffix that is not name or email of another user.
+func (s *Store) uniqueName(ctx context.Context, tx *sql.Tx, name string) (string, error) {
+	if len(name) == 0 {
+		name = "user"
+	}
+	candidate := name
+	for i := 2; ; i++ {
+		var n int
+		err := tx.QueryRowContext(ctx, s.dialect.Rebind(`SELECT COUNT(*) FROM auth_users WHERE name = ? OR email = ?`),
+			candidate, strings.ToLower(candidate)).Scan(&n)
+		if err != nil {
+			return "", err
+		}
+		if n == 0 {
+			return candidate, nil
+		}
+		candidate = name + "-" + strconv.Itoa(i)
+	}
+}
+
+// UpdateAccount updates profile of the user and links OAuth account if data has provider.
+// Role of data is ignored like in CreateUser.
+func (s *Store) UpdateAccount(ctx context.Context, user auth.User, data auth.UserData) error {
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		sets := []string{"updated_at = ?"}
+		args := []interface{}{s.now()}
+		fields := []struct {
+			column string
+			value  string
+		}{
+			{"first_name", data.FirstName},
+			{"last_name", data.LastName},
+			{"nick_name", data.NickName},
+			{"avatar_url", data.AvatarURL},
+			{"location", data.Location},
+		}
+		for _, f := range fields {
+			if len(f.value) > 0 {
+				sets = append(sets, f.column+" = ?")
+				args = append(args, f.value)
+			}
+		}
+		if len(data.Password) > 0 {
+			hash, err := s.hasher.Hash(data.Password)
+			if err != nil {
+				return err
+			}
+			sets = append(sets, "password_hash = ?")
+			args = append(args, hash)
+		}
+		args = append(args, user.GetID())
+		res, err := tx.ExecContext(ctx, s.dialect.Rebind(`UPDATE auth_users SET `+strings.Join(sets, ", ")+` WHERE id = ?`), args...)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return ErrNotFound
+		}
+		if len(data.Provider) > 0 {
+			return s.linkAccount(ctx, tx, user.GetID(), data)
+		}
+		return nil
+	})
+}
+
+func (s *Store) linkAccount(ctx context.Context, tx *sql.Tx, userID string, data auth.UserData) error {
+	accessToken, err := s.box.seal(data.AccessToken)
+	if err != nil {
+		return err
+	}
+	accessTokenSecret, err := s.box.seal(data.AccessTokenSecret)
+	if err != nil {
+		return err
+	}
+	refreshToken, err := s.box.seal(data.RefreshToken)
+	if err != nil {
+		return err
+	}
+	expiresAt := sql.NullTime{Time: data.ExpiresAt.UTC(), Valid: !data.ExpiresAt.IsZero()}
+
+	_, err = tx.ExecContext(ctx, s.dialect.Rebind(`INSERT INTO auth_accounts
+		(provider, provider_user_id, user_id, access_token, access_token_secret, refresh_token, expires_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (provider, provider_user_id) DO UPDATE SET
+		user_id = excluded.user_id,
+		access_token = excluded.access_token,
+		access_token_secret = excluded.access_token_secret,
+		refresh_token = excluded.refresh_token,
+		expires_at = excluded.expires_at,
+		updated_at = excluded.updated_at`),
+		data.Provider, data.UserID, userID, accessToken, accessTokenSecret, refreshToken, expiresAt, s.now())
+	return err
+}
+
+// Accounts returns OAuth accounts linked to given user with decrypted tokens.
+func (s *Store) Accounts(ctx context.Context, userID string) ([]*Account, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(`SELECT provider, provider_user_id, user_id,
+		access_token, access_token_secret, refresh_token, expires_at
+		FROM auth_accounts WHERE user_id = ? ORDER BY provider`), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*Account
+	for rows.Next() {
+		a := &Account{}
+		var accessToken, accessTokenSecret, refreshToken string
+		var expiresAt sql.NullTime
+		err := rows.Scan(&a.Provider, &a.ProviderUserID, &a.UserID, &accessToken, &accessTokenSecret, &refreshToken, &expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		if a.AccessToken, err = s.box.open(accessToken); err != nil {
+			return nil, err
+		}
+		if a.AccessTokenSecret, err = s.box.open(accessTokenSecret); err != nil {
+			return nil, err
+		}
+		if a.RefreshToken, err = s.box.open(refreshToken); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			a.ExpiresAt = expiresAt.Time
+		}
+		result = append(result, a)
+	}
+	return result, rows.Err()
+}
+
+// SetPassword replaces password of given user.
+func (s *Store) SetPassword(ctx context.Context, userID, pwd string) error {
+	return s.UpdateAccount(ctx, &User{ID: userID}, auth.UserData{Password: pwd})
+}
+
+// AddRole grants role to given user.
+func (s *Store) AddRole(ctx context.Context, userID, role string) error {
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		return s.addRole(ctx, tx, userID, role)
+	})
+}
+
+// RemoveRole revokes role from given user.
+func (s *Store) RemoveRole(ctx context.Context, userID, role string) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(`DELETE FROM auth_roles WHERE user_id = ? AND role = ?`), userID, role)
+	return err
+}
+
+func (s *Store) addRole(ctx context.Context, tx *sql.Tx, userID, role string) error {
+	_, err := tx.ExecContext(ctx, s.dialect.Rebind(`INSERT INTO auth_roles (user_id, role) VALUES (?, ?)
+		ON CONFLICT (user_id, role) DO NOTHING`), userID, role)
+	return err
+}
+
+// Close does nothing, database passed to New is owned and closed by caller.
+func (s *Store) Close() {
+}
+
+func (s *Store) tx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if len(v) > 0 {
+			return v
+		}
+	}
+	return ""
+}
+
+var (
+	_ auth.UserStore   = (*Store)(nil)
+	_ auth.UserStoreEx = (*Store)(nil)
+
+	_ auth.PasswordUpgrader = (*Store)(nil)
+	_ auth.AccountFinder    = (*Store)(nil)
+)
@@ -0,0 +1,295 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocontrib/auth"
+	"github.com/gocontrib/auth/password"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testKey    = []byte("0123456789abcdef0123456789abcdef")
+	testHasher = &password.Argon2id{Time: 1, Memory: 1024, Threads: 1}
+)
+
+func openTestStore(t *testing.T) *Store {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each connection of :memory: database is separate database
+	db.SetMaxOpenConns(1)
+	s, err := New(db, Options{EncryptionKey: testKey, Hasher: testHasher})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return s
+}
+
+func TestMigrate_Idempotent(t *testing.T) {
+	s := openTestStore(t)
+	assert.Nil(t, s.Migrate(context.Background()))
+
+	var version int
+	s.db.QueryRow(`SELECT MAX(version) FROM auth_migrations`).Scan(&version)
+	assert.Equal(t, len(migrations), version)
+}
+
+func TestMigrate_Concurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dsn := "file:" + filepath.Join(dir, "auth.db") + "?_busy_timeout=5000"
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		db, err := sql.Open("sqlite3", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		s, _ := New(db, Options{Hasher: testHasher})
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.Migrate(context.Background())
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.Nil(t, err)
+	}
+}
+
+func TestStore_CloseKeepsDatabase(t *testing.T) {
+	s := openTestStore(t)
+	s.Close()
+	assert.Nil(t, s.db.Ping())
+}
+
+func TestStore_NameCannotShadowEmail(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	victim, err := s.CreateUser(ctx, auth.UserData{Name: "victim", Email: "victim@test.net", Password: "secret"})
+	assert.Nil(t, err)
+	_, err = s.CreateUser(ctx, auth.UserData{Name: "victim@test.net", Password: "attacker"})
+	assert.Nil(t, err)
+
+	u, err := s.ValidateCredentials(ctx, "victim@test.net", "secret")
+	assert.Nil(t, err)
+	assert.Equal(t, victim.GetID(), u.GetID())
+	_, err = s.ValidateCredentials(ctx, "victim@test.net", "attacker")
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestStore_Credentials(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	user, err := s.CreateUser(ctx, auth.UserData{
+		Name:     "bob",
+		Email:    "Bob@Test.net",
+		Password: "b0b",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "bob@test.net", user.GetEmail())
+	assert.False(t, user.IsAdmin())
+
+	u, err := s.ValidateCredentials(ctx, "bob", "b0b")
+	assert.Nil(t, err)
+	assert.Equal(t, user.GetID(), u.GetID())
+
+	u, err = s.ValidateCredentials(ctx, "bob@test.net", "b0b")
+	assert.Nil(t, err)
+	assert.Equal(t, user.GetID(), u.GetID())
+
+	_, err = s.ValidateCredentials(ctx, "bob", "wrong")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = s.ValidateCredentials(ctx, "joe", "b0b")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	var hash string
+	s.db.QueryRow(`SELECT password_hash FROM auth_users WHERE id = ?`, user.GetID()).Scan(&hash)
+	assert.NotContains(t, hash, "b0b")
+
+	assert.Nil(t, s.SetPassword(ctx, user.GetID(), "new"))
+	_, err = s.ValidateCredentials(ctx, "bob", "b0b")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = s.ValidateCredentials(ctx, "bob", "new")
+	assert.Nil(t, err)
+
+}
+
+func TestStore_UniqueName(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	for i, want := range []string{"John Smith", "John Smith-2", "John Smith-3"} {
+		user, err := s.CreateUser(ctx, auth.UserData{
+			Name:     "John Smith",
+			Provider: "github",
+			UserID:   strconv.Itoa(i),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, want, user.GetName())
+	}
+
+	user, err := s.CreateUser(ctx, auth.UserData{})
+	assert.Nil(t, err)
+	assert.Equal(t, "user", user.GetName())
+}
+
+func TestStore_Roles(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	user, err := s.CreateUser(ctx, auth.UserData{Name: "admin"})
+	assert.Nil(t, err)
+	assert.Nil(t, s.AddRole(ctx, user.GetID(), "admin"))
+	assert.Nil(t, s.AddRole(ctx, user.GetID(), "support"))
+	assert.Nil(t, s.AddRole(ctx, user.GetID(), "support"))
+	u, _ := s.FindUserByID(ctx, user.GetID())
+	assert.Equal(t, []string{"admin", "support"}, u.(*User).Roles)
+	assert.Equal(t, []string{"admin", "support"}, u.GetClaims()["roles"])
+
+	assert.Nil(t, s.RemoveRole(ctx, user.GetID(), "admin"))
+	u, _ = s.FindUserByID(ctx, user.GetID())
+	assert.False(t, u.IsAdmin())
+
+	_, err = s.FindUserByID(ctx, "unknown")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestStore_RegisterIgnoresRole(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+	config := (&auth.Config{UserStore: s, UserStoreEx: s}).SetDefaults()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/register",
+		strings.NewReader(`{"name": "eve", "email": "eve@test.net", "role": "admin"}`))
+	auth.RegisterHandler(config).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	user, err := s.FindUserByEmail(ctx, "eve@test.net")
+	assert.Nil(t, err)
+	assert.False(t, user.IsAdmin())
+
+	user, err = s.CreateUser(ctx, auth.UserData{Name: "mallory", Role: "admin"})
+	assert.Nil(t, err)
+	assert.False(t, user.IsAdmin())
+	assert.Nil(t, s.UpdateAccount(ctx, user, auth.UserData{Role: "admin"}))
+	user, _ = s.FindUserByID(ctx, user.GetID())
+	assert.False(t, user.IsAdmin())
+}
+
+func TestStore_Accounts(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	user, err := s.CreateUser(ctx, auth.UserData{
+		Provider:     "github",
+		UserID:       "gh-1",
+		Name:         "Bob Smith",
+		Email:        "bob@test.net",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		ExpiresAt:    expires,
+	})
+	assert.Nil(t, err)
+
+	u, err := s.FindUserByEmail(ctx, "bob@test.net")
+	assert.Nil(t, err)
+	assert.Equal(t, user.GetID(), u.GetID())
+
+	u, err = s.FindUserByAccount(ctx, "github", "gh-1")
+	assert.Nil(t, err)
+	assert.Equal(t, user.GetID(), u.GetID())
+
+	// tokens are encrypted at rest
+	var stored string
+	s.db.QueryRow(`SELECT access_token FROM auth_accounts`).Scan(&stored)
+	assert.NotEmpty(t, stored)
+	assert.NotContains(t, stored, "access")
+
+	err = s.UpdateAccount(ctx, user, auth.UserData{
+		Provider:    "github",
+		UserID:      "gh-1",
+		AccessToken: "access2",
+		AvatarURL:   "https://avatars.test.net/bob",
+	})
+	assert.Nil(t, err)
+	err = s.UpdateAccount(ctx, user, auth.UserData{Provider: "google", UserID: "g-1"})
+	assert.Nil(t, err)
+
+	accounts, err := s.Accounts(ctx, user.GetID())
+	assert.Nil(t, err)
+	assert.Len(t, accounts, 2)
+	assert.Equal(t, "github", accounts[0].Provider)
+	assert.Equal(t, "access2", accounts[0].AccessToken)
+	assert.Equal(t, "", accounts[0].RefreshToken)
+	assert.Equal(t, "google", accounts[1].Provider)
+
+	u, _ = s.FindUserByID(ctx, user.GetID())
+	assert.Equal(t, "https://avatars.test.net/bob", u.(*User).AvatarURL)
+
+	// OAuth users without password cannot log in with password
+	_, err = s.ValidateCredentials(ctx, "Bob Smith", "")
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestPostgresRebind(t *testing.T) {
+	assert.Equal(t, "SELECT * FROM t WHERE a = $1 AND b = $2", PostgreSQL.Rebind("SELECT * FROM t WHERE a = ? AND b = ?"))
+	assert.Equal(t, "SELECT ?", SQLite.Rebind("SELECT ?"))
+}
+
+func TestStore_Rehash(t *testing.T) {
+	ctx := context.Background()
+	outdated, _ := (&password.Argon2id{Time: 2, Memory: 1024, Threads: 1}).Hash("b0b")
+	bcrypt, _ := (&password.Bcrypt{Cost: 4}).Hash("b0b")
+	for name, stored := range map[string]string{
+		"outdated parameters": outdated,
+		"other algorithm":     bcrypt,
+		// Django PBKDF2 hash of 'b0b'
+		"legacy": "pbkdf2_sha256$1000$salt$p7D/v53EmYpQ5d8ZdHFLWbpeCRgjmWxKqp/Rv9Ouib8=",
+	} {
+		s := openTestStore(t)
+		user, _ := s.CreateUser(ctx, auth.UserData{Name: "bob"})
+		_, err := s.db.Exec(`UPDATE auth_users SET password_hash = ? WHERE id = ?`, stored, user.GetID())
+		assert.Nil(t, err)
+
+		u, err := s.ValidateCredentials(ctx, "bob", "b0b")
+		assert.Nil(t, err, name)
+		assert.Nil(t, s.UpgradePassword(ctx, u, "b0b"), name)
+
+		var hash string
+		assert.Nil(t, s.db.QueryRow(`SELECT password_hash FROM auth_users WHERE id = ?`, user.GetID()).Scan(&hash))
+		assert.NotEqual(t, stored, hash, name)
+		assert.False(t, testHasher.NeedsRehash(hash), name)
+
+		_, err = s.ValidateCredentials(ctx, "bob", "b0b")
+		assert.Nil(t, err, name)
+	}
+}
@@ -7,6 +7,8 @@ import (
 )
 
 type Token struct {
+	// ID is unique 'jti' claim of tokens that can be revoked, e.g. impersonation ones
+	ID        string                 `json:"jti,omitempty"`
 	UserID    string                 `json:"user_id"`
 	UserName  string                 `json:"user_name"`
 	Domain    string                 `json:"domain"`
@@ -15,6 +17,14 @@ type Token struct {
 	Issuer    string                 `json:"issuer"`
 	ClientIP  string                 `json:"client_ip"`
 	Claims    map[string]interface{} `json:"claims"` // custom claims
+	// Actor is admin impersonating the user, it is encoded as RFC 8693 'act' claim
+	Actor *Actor `json:"act,omitempty"`
+}
+
+// Actor identifies party acting on behalf of token subject.
+type Actor struct {
+	UserID   string `json:"sub"`
+	UserName string `json:"name,omitempty"`
 }
 
 func (t *Token) Encode(config *Config) (string, *Error) {
@@ -39,6 +49,20 @@ func (t *Token) Encode(config *Config) (string, *Error) {
 	claims["domain"] = t.Domain
 	claims["exp"] = t.ExpiredAt.Unix()
 
+	delete(claims, "jti")
+	if len(t.ID) > 0 {
+		claims["jti"] = t.ID
+	}
+
+	// act claim must never come from user defined claims
+	delete(claims, "act")
+	if t.Actor != nil {
+		claims["act"] = map[string]interface{}{
+			"sub":  t.Actor.UserID,
+			"name": t.Actor.UserName,
+		}
+	}
+
 	if len(t.ClientIP) > 0 {
 		claims["aud"] = t.ClientIP
 	}
@@ -73,9 +97,6 @@ func parseToken(config *Config, tokenString, expectedAudience string, allowExpir
 	}
 
 	clientIP := getString(claims, "aud")
-	if len(expectedAudience) > 0 && len(clientIP) > 0 && clientIP != expectedAudience {
-		return nil, ErrInvalidClientIP
-	}
 
 	// check required fields
 	userID := getString(claims, "user_id")
@@ -96,7 +117,13 @@ func parseToken(config *Config, tokenString, expectedAudience string, allowExpir
 
 	userName := getString(claims, "user_name")
 
-	return &Token{
+	actor, err2 := getActor(claims)
+	if err2 != nil {
+		return nil, err2
+	}
+
+	token := &Token{
+		ID:        getString(claims, "jti"),
 		UserID:    userID,
 		UserName:  userName,
 		Domain:    getString(claims, "domain"),
@@ -104,5 +131,34 @@ func parseToken(config *Config, tokenString, expectedAudience string, allowExpir
 		ExpiredAt: Timestamp(*exp),
 		Issuer:    issuer,
 		ClientIP:  clientIP,
-	}, nil
+		Actor:     actor,
+	}
+	// signed token is returned with the error, so rejection can be attributed to its user
+	if !matchClientIP(config, clientIP, expectedAudience) {
+		return token, ErrInvalidClientIP
+	}
+	return token, nil
+}
+
+// getActor reads RFC 8693 'act' claim, nested actors are rejected.
+func getActor(claims jwt.MapClaims) (*Actor, *Error) {
+	v, ok := claims["act"]
+	if !ok {
+		return nil, nil
+	}
+	act, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if _, nested := act["act"]; nested {
+		return nil, ErrImpersonationNested
+	}
+	actor := &Actor{
+		UserID:   getString(act, "sub"),
+		UserName: getString(act, "name"),
+	}
+	if len(actor.UserID) == 0 {
+		return nil, ErrInvalidToken
+	}
+	return actor, nil
 }
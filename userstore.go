@@ -35,8 +35,10 @@ type UserData struct {
 	AccessTokenSecret string
 	RefreshToken      string
 	ExpiresAt         time.Time
-	Role              string
-	Password          string
+	// Role is not granted by CreateUser and UpdateAccount, data comes from
+	// self-registration and OAuth profiles. Grant roles with store specific API.
+	Role     string
+	Password string
 }
 
 type UserStoreEx interface {
@@ -45,6 +47,19 @@ type UserStoreEx interface {
 	UpdateAccount(ctx context.Context, user User, data UserData) error
 }
 
+// PasswordUpgrader is optionally implemented by UserStore to migrate password hashes.
+// UpgradePassword is called after successful login by LoginHandler with verified password,
+// so store can re-hash legacy or outdated hash with current algorithm.
+type PasswordUpgrader interface {
+	UpgradePassword(ctx context.Context, user User, password string) error
+}
+
+// AccountFinder is optionally implemented by UserStoreEx to find users by linked OAuth account,
+// so accounts without email or with changed email are matched on next logins.
+type AccountFinder interface {
+	FindUserByAccount(ctx context.Context, provider, providerUserID string) (User, error)
+}
+
 type UserInfo struct {
 	ID     string
 	Name   string
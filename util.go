@@ -2,13 +2,13 @@ package auth
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
 	"os"
 	"strconv"
 	"time"
 
 	log "github.com/sirupsen/logrus"
-	"github.com/tomasen/realip"
 )
 
 const (
@@ -25,10 +25,6 @@ var getIssuer = func() string {
 	return hostname
 }
 
-var getClientIP = func(r *http.Request) string {
-	return realip.RealIP(r)
-}
-
 func getString(data map[string]interface{}, key string) string {
 	v, ok := data[key]
 	if !ok {
@@ -71,12 +67,48 @@ func getTime(data map[string]interface{}, key string) *time.Time {
 	}
 }
 
+var defaultLogConfig = &Config{
+	Logger: LogrusLogger(log.StandardLogger()),
+}
+
+// SendError logs error with standard logrus logger and writes it to response in legacy format.
 func SendError(w http.ResponseWriter, err *Error) {
-	s, _ := json.Marshal(err)
-	log.Errorf("AUTH ERROR: %s", string(s))
-	w.Header().Set("Content-Type", contentJSON)
-	w.WriteHeader(err.Status)
-	SendJSON(w, err)
+	defaultLogConfig.LogError(nil, err)
+	writeError(defaultLogConfig, w, nil, err)
+}
+
+// SendRequestError logs error with Config.Logger and writes it in format negotiated with client.
+// Response body is rendered by Config.ErrorHandler if it is set, it receives localized error.
+func SendRequestError(config *Config, w http.ResponseWriter, r *http.Request, err *Error) {
+	config.LogError(r, err)
+	writeError(config, w, r, err)
+}
+
+func writeError(config *Config, w http.ResponseWriter, r *http.Request, err *Error) {
+	writeErrorHeaders(w, err)
+	err, lang := localizeError(config, r, err)
+	if config.Messages != nil {
+		w.Header().Add("Vary", "Accept-Language")
+	}
+	if len(lang) > 0 {
+		w.Header().Set("Content-Language", lang)
+	}
+	if config.ErrorHandler != nil {
+		config.ErrorHandler(w, r, err)
+		return
+	}
+	renderError(config, w, r, err)
+}
+
+// writeErrorHeaders sets WWW-Authenticate and Retry-After headers.
+func writeErrorHeaders(w http.ResponseWriter, err *Error) {
+	for _, challenge := range err.Challenges {
+		w.Header().Add("WWW-Authenticate", challenge)
+	}
+	if err.RetryAfter > 0 {
+		seconds := int64(math.Ceil(err.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
 }
 
 func SendJSON(w http.ResponseWriter, result interface{}) {
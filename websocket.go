@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+const (
+	defaultTicketTTL     = 30 * time.Second
+	defaultTicketParam   = "ticket"
+	defaultCheckInterval = time.Minute
+)
+
+// WebSocketPolicy configures authentication of WebSocket connections.
+type WebSocketPolicy struct {
+	// Tickets keeps single-use tickets minted by TicketHandler, tickets are disabled if nil
+	Tickets TicketStore
+	// TicketTTL is lifetime of unredeemed ticket, 30 seconds by default
+	TicketTTL time.Duration
+	// TicketParam is query parameter with ticket, 'ticket' by default.
+	// Tickets are query string credentials, so Config.DisableQueryToken and Config.QueryTokenPaths apply to them.
+	TicketParam string
+	// CheckInterval is how often WatchSession checks revocation, one minute by default
+	CheckInterval time.Duration
+	// IsRevoked reports whether session of long-lived connection is revoked
+	IsRevoked func(ctx context.Context, user User, token *Token) bool
+}
+
+func (p *WebSocketPolicy) ticketTTL() time.Duration {
+	if p.TicketTTL > 0 {
+		return p.TicketTTL
+	}
+	return defaultTicketTTL
+}
+
+func (p *WebSocketPolicy) ticketParam() string {
+	if len(p.TicketParam) > 0 {
+		return p.TicketParam
+	}
+	return defaultTicketParam
+}
+
+func (p *WebSocketPolicy) checkInterval() time.Duration {
+	if p != nil && p.CheckInterval > 0 {
+		return p.CheckInterval
+	}
+	return defaultCheckInterval
+}
+
+// Ticket is single-use credential to authenticate WebSocket upgrade.
+type Ticket struct {
+	ID     string `json:"ticket"`
+	UserID string `json:"-"`
+	// TokenID is ID of token the ticket was minted with, it is set for revocable tokens
+	TokenID   string    `json:"-"`
+	ClientIP  string    `json:"-"`
+	ExpiredAt time.Time `json:"expired_at"`
+	// SessionExpiredAt is expiration of token the ticket was minted with, zero if it does not expire
+	SessionExpiredAt time.Time `json:"-"`
+	// AuthTime is when user has entered credentials
+	AuthTime time.Time `json:"-"`
+	// Actor is admin impersonating the user
+	Actor *Actor `json:"-"`
+}
+
+// TicketStore keeps WebSocket tickets. Take must remove ticket, so it can be redeemed only once.
+type TicketStore interface {
+	Put(ctx context.Context, ticket *Ticket) error
+	// Take returns and removes ticket with given ID, it returns nil if there is no such ticket
+	Take(ctx context.Context, id string) (*Ticket, error)
+}
+
+func ticketsEnabled(config *Config) bool {
+	return config.WebSocket != nil && config.WebSocket.Tickets != nil
+}
+
+// TicketHandler mints WebSocket ticket for authenticated user.
+func TicketHandler(config *Config) http.Handler {
+	config = config.SetDefaults()
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ticketsEnabled(config) {
+			SendRequestError(config, w, r, ErrBadState)
+			return
+		}
+		policy := config.WebSocket
+		user := GetRequestUser(r)
+		ticket := &Ticket{
+			ID:        base64.RawURLEncoding.EncodeToString(securecookie.GenerateRandomKey(32)),
+			UserID:    user.GetID(),
+			ClientIP:  getClientIP(config, r),
+			ExpiredAt: now().Add(policy.ticketTTL()),
+		}
+		if info := GetAuthInfo(r.Context()); info != nil {
+			ticket.AuthTime = info.AuthTime
+			if info.Token != nil {
+				ticket.SessionExpiredAt = time.Time(info.Token.ExpiredAt)
+				ticket.TokenID = info.Token.ID
+				ticket.Actor = info.Token.Actor
+			}
+		}
+		err := policy.Tickets.Put(r.Context(), ticket)
+		if err != nil {
+			SendRequestError(config, w, r, ErrBadState.WithCause(err))
+			return
+		}
+		SendJSON(w, ticket)
+	})
+
+	return RequireUser(config)(h)
+}
+
+// redeemTicket validates ticket from WebSocket upgrade request.
+// It returns nil token if request has no ticket.
+func redeemTicket(config *Config, r *http.Request) (*Token, User, *Error) {
+	if !ticketsEnabled(config) || !isWebSocketUpgrade(r) || !queryTokenAllowed(config, r) {
+		return nil, nil, nil
+	}
+	policy := config.WebSocket
+	id := r.URL.Query().Get(policy.ticketParam())
+	if len(id) == 0 {
+		return nil, nil, nil
+	}
+
+	ticket, err := policy.Tickets.Take(r.Context(), id)
+	if err != nil {
+		return nil, nil, ErrBadState.WithCause(err)
+	}
+	if ticket == nil || !now().Before(ticket.ExpiredAt) {
+		return nil, nil, ErrInvalidTicket
+	}
+	if !matchClientIP(config, ticket.ClientIP, getClientIP(config, r)) {
+		return nil, nil, ErrInvalidClientIP
+	}
+
+	user, error := callFindUserByID(config, r.Context(), ticket.UserID)
+	if error != nil {
+		return nil, nil, ErrUserNotFound.WithCause(error)
+	}
+
+	token := &Token{
+		ID:        ticket.TokenID,
+		UserID:    ticket.UserID,
+		UserName:  user.GetName(),
+		IssuedAt:  Timestamp(ticket.AuthTime),
+		ExpiredAt: Timestamp(ticket.SessionExpiredAt),
+		ClientIP:  ticket.ClientIP,
+		Actor:     ticket.Actor,
+	}
+	return token, user, nil
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") &&
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func headerContains(h http.Header, name, value string) bool {
+	for _, v := range splitList(h[http.CanonicalHeaderKey(name)]) {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// WebSocketProtocol returns subprotocol the server should echo back on upgrade
+// when token was passed in Sec-WebSocket-Protocol. Clients should offer application
+// protocol along with token one, otherwise token protocol itself is echoed.
+// Middleware does not set response headers, pass WebSocketResponseHeader to the upgrader.
+func WebSocketProtocol(r *http.Request) string {
+	if v, ok := r.Context().Value(webSocketProtocolKey).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// WebSocketResponseHeader returns response header with selected subprotocol to pass to the upgrader,
+// e.g. gorilla websocket.Upgrader.Upgrade(w, r, auth.WebSocketResponseHeader(r)).
+// It returns nil if token was not passed in Sec-WebSocket-Protocol.
+func WebSocketResponseHeader(r *http.Request) http.Header {
+	protocol := WebSocketProtocol(r)
+	if len(protocol) == 0 {
+		return nil
+	}
+	header := http.Header{}
+	header.Set(webSocketProtocolHeader, protocol)
+	return header
+}
+
+// selectWebSocketProtocol chooses protocol to echo if given token protocol was offered.
+func selectWebSocketProtocol(r *http.Request, tokenProtocol string) string {
+	var found bool
+	var selected string
+	for _, protocol := range splitList(r.Header[http.CanonicalHeaderKey(webSocketProtocolHeader)]) {
+		if protocol == tokenProtocol {
+			found = true
+		} else if len(selected) == 0 {
+			selected = protocol
+		}
+	}
+	if !found {
+		return ""
+	}
+	if len(selected) == 0 {
+		return tokenProtocol
+	}
+	return selected
+}
+
+// WatchSession blocks until authentication of long-lived connection expires or is revoked
+// and then calls onClose with the reason. It returns without calling onClose when ctx is done.
+// The ctx should be request context passed through RequireUser middleware.
+func WatchSession(ctx context.Context, config *Config, onClose func(err *Error)) {
+	user := GetContextUser(ctx)
+	token := getContextToken(ctx)
+
+	var expired <-chan time.Time
+	if token != nil && !time.Time(token.ExpiredAt).IsZero() {
+		timer := time.NewTimer(time.Time(token.ExpiredAt).Sub(now()))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	ticker := time.NewTicker(config.WebSocket.checkInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-expired:
+			onClose(ErrSessionExpired)
+			return
+		case <-ticker.C:
+			if user == nil {
+				continue
+			}
+			if _, err := callFindUserByID(config, ctx, user.GetID()); err != nil {
+				onClose(ErrSessionRevoked.WithCause(err))
+				return
+			}
+			if token != nil && token.Actor != nil && checkRevoked(config, ctx, token) != nil {
+				onClose(ErrSessionRevoked)
+				return
+			}
+			if config.WebSocket != nil && config.WebSocket.IsRevoked != nil && config.WebSocket.IsRevoked(ctx, user, token) {
+				onClose(ErrSessionRevoked)
+				return
+			}
+		}
+	}
+}
+
+// NewMemoryTicketStore creates in-memory TicketStore for single instance deployments.
+func NewMemoryTicketStore() TicketStore {
+	return &memoryTicketStore{
+		tickets: make(map[string]*Ticket),
+	}
+}
+
+type memoryTicketStore struct {
+	sync.Mutex
+	tickets map[string]*Ticket
+}
+
+func (s *memoryTicketStore) Put(ctx context.Context, ticket *Ticket) error {
+	s.Lock()
+	defer s.Unlock()
+	t := now()
+	for id, v := range s.tickets {
+		if !t.Before(v.ExpiredAt) {
+			delete(s.tickets, id)
+		}
+	}
+	s.tickets[ticket.ID] = ticket
+	return nil
+}
+
+func (s *memoryTicketStore) Take(ctx context.Context, id string) (*Ticket, error) {
+	s.Lock()
+	defer s.Unlock()
+	ticket, ok := s.tickets[id]
+	if !ok {
+		return nil, nil
+	}
+	delete(s.tickets, id)
+	return ticket, nil
+}
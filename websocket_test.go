@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func webSocketHandler(config *Config) http.Handler {
+	return RequireUser(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, GetRequestUser(r).GetName(), " ", WebSocketProtocol(r))
+	}))
+}
+
+func makeUpgradeRequest(target string) *http.Request {
+	r := httptest.NewRequest("GET", target, nil)
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	return r
+}
+
+func TestWebSocketProtocol_Echo(t *testing.T) {
+	config := makeTestConfig()
+	config.TokenExtractors = []TokenExtractor{WebSocketProtocolToken("")}
+	c := &C{T: t, config: config}
+	token := c.makeToken("bob", "b0b")
+	h := webSocketHandler(config)
+
+	r := makeUpgradeRequest("/ws")
+	r.Header.Set(webSocketProtocolHeader, "bearer."+token+", chat.v1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(webSocketProtocolHeader))
+	assert.Equal(t, "bob chat.v1", w.Body.String())
+
+	var header http.Header
+	h = RequireUser(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = WebSocketResponseHeader(r)
+	}))
+	r = makeUpgradeRequest("/ws")
+	r.Header.Set(webSocketProtocolHeader, "bearer."+token)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bearer."+token, header.Get(webSocketProtocolHeader))
+}
+
+func TestWebSocketProtocol_OnlyTokenProtocol(t *testing.T) {
+	config := makeTestConfig()
+	config.TokenExtractors = []TokenExtractor{AuthorizationToken, WebSocketProtocolToken("")}
+	c := &C{T: t, config: config}
+	token := c.makeToken("bob", "b0b")
+	h := webSocketHandler(config)
+
+	// token from other source is never matched against subprotocols
+	r := makeUpgradeRequest("/ws")
+	r.Header.Set(authorizationHeader, "Bearer "+token)
+	r.Header.Set(webSocketProtocolHeader, "chat.v1, x.bearer."+token)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bob ", w.Body.String())
+
+	config.WebSocketProtocolPrefix = "access_token."
+	config.TokenExtractors = []TokenExtractor{WebSocketProtocolToken("")}
+	r = makeUpgradeRequest("/ws")
+	r.Header.Set(webSocketProtocolHeader, "access_token."+token)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bob access_token."+token, w.Body.String())
+}
+
+func TestTicket_Redeem(t *testing.T) {
+	config := makeTestConfig()
+	config.WebSocket = &WebSocketPolicy{Tickets: NewMemoryTicketStore()}
+	h := webSocketHandler(config)
+
+	r := httptest.NewRequest("POST", "/ticket", nil)
+	r.SetBasicAuth("bob", "b0b")
+	w := httptest.NewRecorder()
+	TicketHandler(config).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	ticket := &Ticket{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), ticket))
+	assert.NotEmpty(t, ticket.ID)
+
+	// ticket is accepted only on upgrade
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/ws?ticket="+ticket.ID, nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, makeUpgradeRequest("/ws?ticket="+ticket.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bob ", w.Body.String())
+
+	// single use
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, makeUpgradeRequest("/ws?ticket="+ticket.ID))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), ErrInvalidTicket.Code)
+}
+
+func TestTicket_DisableQueryToken(t *testing.T) {
+	config := makeTestConfig()
+	config.DisableQueryToken = true
+	config.WebSocket = &WebSocketPolicy{Tickets: NewMemoryTicketStore()}
+	user, _ := config.UserStore.ValidateCredentials(context.Background(), "bob", "b0b")
+	config.WebSocket.Tickets.Put(context.Background(), &Ticket{
+		ID:        "valid",
+		UserID:    user.GetID(),
+		ExpiredAt: time.Now().Add(time.Minute),
+	})
+
+	w := httptest.NewRecorder()
+	webSocketHandler(config).ServeHTTP(w, makeUpgradeRequest("/ws?ticket=valid"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	ticket, _ := config.WebSocket.Tickets.Take(context.Background(), "valid")
+	assert.NotNil(t, ticket)
+}
+
+func TestTicket_Expired(t *testing.T) {
+	config := makeTestConfig()
+	config.WebSocket = &WebSocketPolicy{Tickets: NewMemoryTicketStore()}
+	user, _ := config.UserStore.ValidateCredentials(context.Background(), "bob", "b0b")
+	config.WebSocket.Tickets.Put(context.Background(), &Ticket{
+		ID:        "expired",
+		UserID:    user.GetID(),
+		ExpiredAt: time.Now().Add(-time.Second),
+	})
+
+	w := httptest.NewRecorder()
+	webSocketHandler(config).ServeHTTP(w, makeUpgradeRequest("/ws?ticket=expired"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestWatchSession_Expired(t *testing.T) {
+	config := makeTestConfig()
+	user, _ := config.UserStore.ValidateCredentials(context.Background(), "bob", "b0b")
+	ctx := WithAuthInfo(context.Background(), &AuthInfo{
+		User:   user,
+		Token:  &Token{UserID: user.GetID(), ExpiredAt: Timestamp(time.Now().Add(10 * time.Millisecond))},
+		Method: AuthMethodBearer,
+	})
+
+	var reason *Error
+	WatchSession(ctx, config, func(err *Error) { reason = err })
+	assert.Equal(t, ErrSessionExpired, reason)
+}
+
+func TestWatchSession_Revoked(t *testing.T) {
+	config := makeTestConfig()
+	config.WebSocket = &WebSocketPolicy{
+		CheckInterval: 5 * time.Millisecond,
+		IsRevoked: func(ctx context.Context, user User, token *Token) bool {
+			return user.GetName() == "bob"
+		},
+	}
+	user, _ := config.UserStore.ValidateCredentials(context.Background(), "bob", "b0b")
+	ctx := WithUser(context.Background(), user)
+
+	var reason *Error
+	WatchSession(ctx, config, func(err *Error) { reason = err })
+	assert.Equal(t, ErrSessionRevoked, reason)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	reason = nil
+	WatchSession(WithUser(ctx, &UserInfo{ID: "unknown"}), config, func(err *Error) { reason = err })
+	assert.Equal(t, ErrSessionRevoked.Code, reason.Code)
+}